@@ -29,9 +29,9 @@ __attribute__((constructor)) void enter_namespace(void) {
 	}
 	int i;
 	char nspath[1024];
-	char *namespaces[] = { "ipc", "uts", "net", "pid", "mnt" };
+	char *namespaces[] = { "ipc", "uts", "net", "pid", "cgroup", "mnt" };
 
-	for (i=0; i<5; i++) {
+	for (i=0; i<6; i++) {
 		sprintf(nspath, "/proc/%s/ns/%s", mydocker_pid, namespaces[i]);
 		int fd = open(nspath, O_RDONLY);
 
@@ -2,50 +2,210 @@ package cgroup
 
 import (
 	"docker-my/cgroup/subsystem"
-	"github.com/sirupsen/logrus"
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"strings"
+	"time"
 )
 
+// DefaultCgroupParent is the group every container's own cgroup is created under
+const DefaultCgroupParent = "mydocker"
+
 type CgroupManager struct {
 	// the path of the hierarchy in the cgroup,just like create the file-to the root-group
 	Path string
 	//resource config
 	Resource *subsystem.ResourceConfig
+	//the v1 or the v2 subsystems,depend on the cgroup mounted by the host
+	subsystems []subsystem.SubSystem
+	//the name of the subsystems the host doesn't provide
+	unavailable []string
 }
 
 func NewCGroupManager(path string) *CgroupManager {
-	return &CgroupManager{
-		Path: path,
+	candidates := subsystem.SubSystemIns
+	if subsystem.IsCgroup2UnifiedMode() {
+		candidates = subsystem.SubSystemV2Ins
+	}
+	manager := &CgroupManager{Path: path}
+	for _, subSysIns := range candidates {
+		if subsystem.Available(subSysIns) {
+			manager.subsystems = append(manager.subsystems, subSysIns)
+		} else {
+			manager.unavailable = append(manager.unavailable, subSysIns.Name())
+		}
+	}
+	return manager
+}
+
+// subsystemErrors collect the error of every subsystem instead of stopping at the first one
+type subsystemErrors []string
+
+func (e *subsystemErrors) add(name string, err error) {
+	*e = append(*e, fmt.Sprintf("%s: %v", name, err))
+}
+
+func (e subsystemErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+func (e subsystemErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ContainerCgroupPath return the cgroup path owned by one container,like mydocker/<id>,
+// the parent is DefaultCgroupParent unless the container is created in a group
+func ContainerCgroupPath(parent, containerID string) string {
+	if parent == "" {
+		parent = DefaultCgroupParent
 	}
+	return path.Join(parent, containerID)
 }
 
+// Apply add the process to the cgroup of every subsystem,the errors of all of them are returned
 func (c *CgroupManager) Apply(pid int) error {
-	for _, subSysIns := range subsystem.SubSystemIns {
-		subSysIns.Apply(c.Path, pid)
+	var errs subsystemErrors
+	for _, subSysIns := range c.subsystems {
+		if err := subSysIns.Apply(c.Path, pid); err != nil {
+			errs.add(subSysIns.Name(), err)
+		}
 	}
-	return nil
+	return errs.err()
 }
 
+// Set create the cgroup and write the limits,a limit of the subsystem not provided
+// by the host is an error instead of being dropped silently
 func (c *CgroupManager) Set(res *subsystem.ResourceConfig) error {
-	for _, subSysIns := range subsystem.SubSystemIns {
-		subSysIns.Set(c.Path, res)
+	var errs subsystemErrors
+	for _, name := range c.unavailable {
+		if res.Requires(name) {
+			errs.add(name, fmt.Errorf("subsystem is not available on the host"))
+		}
 	}
-	return nil
+	for _, subSysIns := range c.subsystems {
+		if err := subSysIns.Set(c.Path, res); err != nil {
+			errs.add(subSysIns.Name(), err)
+		}
+	}
+	return errs.err()
 }
 
-func (c *CgroupManager) Destroy() error {
-	for _, subSysIns := range subsystem.SubSystemIns {
-		if err := subSysIns.Remove(c.Path); err != nil {
-			logrus.Warnf("remove cgroup fail %v", err)
+// GetStats read the usage of the cgroup from every subsystem which can report it,
+// the stats are never nil,a subsystem failed to read leave its part empty and its error is returned
+func (c *CgroupManager) GetStats() (*subsystem.Stats, error) {
+	stats := &subsystem.Stats{}
+	var errs subsystemErrors
+	for _, subSysIns := range c.subsystems {
+		if statsIns, ok := subSysIns.(subsystem.StatsSubSystem); ok {
+			if err := statsIns.GetStats(c.Path, stats); err != nil {
+				errs.add(subSysIns.Name(), err)
+			}
+		}
+	}
+	return stats, errs.err()
+}
+
+func (c *CgroupManager) freezer() (subsystem.Freezer, error) {
+	for _, subSysIns := range c.subsystems {
+		if freezer, ok := subSysIns.(subsystem.Freezer); ok {
+			return freezer, nil
+		}
+	}
+	return nil, fmt.Errorf("freezer subsystem not found")
+}
+
+// SetFreezerState ask to suspend or resume all the processes in the cgroup,it does not wait
+func (c *CgroupManager) SetFreezerState(state subsystem.FreezerState) error {
+	freezer, err := c.freezer()
+	if err != nil {
+		return err
+	}
+	return freezer.SetFreezerState(c.Path, state)
+}
+
+// GetFreezerState return the current state of the freezer,Freezing while it is not settled
+func (c *CgroupManager) GetFreezerState() (subsystem.FreezerState, error) {
+	freezer, err := c.freezer()
+	if err != nil {
+		return "", err
+	}
+	return freezer.GetFreezerState(c.Path)
+}
+
+// WaitFreezerState poll the freezer until it settle in the state,retry is called every time it
+// is still freezing,since the v1 freezing interrupted by a new process need the state written again
+func (c *CgroupManager) WaitFreezerState(state subsystem.FreezerState, retry func() error) error {
+	freezer, err := c.freezer()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(subsystem.FreezeTimeout)
+	for {
+		current, err := freezer.GetFreezerState(c.Path)
+		if err != nil {
+			return err
+		}
+		if current == state {
+			return nil
+		}
+		//someone else changed the state meanwhile
+		if current != subsystem.Freezing {
+			return fmt.Errorf("freezer state is %s while waiting for %s", current, state)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait freezer state %s timeout", state)
+		}
+		if retry != nil {
+			if err := retry(); err != nil {
+				return err
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Freeze suspend or resume all the processes in the cgroup and wait for it
+func (c *CgroupManager) Freeze(state subsystem.FreezerState) error {
+	if err := c.SetFreezerState(state); err != nil {
+		return err
+	}
+	return c.WaitFreezerState(state, func() error {
+		return c.SetFreezerState(state)
+	})
+}
+
+// NotifyOOM return a channel receiving a value after a process of the cgroup is oom killed
+func (c *CgroupManager) NotifyOOM() (<-chan struct{}, error) {
+	for _, subSysIns := range c.subsystems {
+		if notifier, ok := subSysIns.(subsystem.OOMNotifier); ok {
+			return notifier.NotifyOOM(c.Path)
 		}
 	}
-	return nil
+	return nil, fmt.Errorf("memory subsystem not found")
 }
 
-func sendInitCommand(comArray []string, writePipe *os.File) {
-	command := strings.Join(comArray, " ")
-	logrus.Infof("command all is %s", command)
-	writePipe.WriteString(command)
-	writePipe.Close()
+// OOMKillCount return the number of the processes of the cgroup killed by the oom killer
+func (c *CgroupManager) OOMKillCount() (uint64, error) {
+	for _, subSysIns := range c.subsystems {
+		if notifier, ok := subSysIns.(subsystem.OOMNotifier); ok {
+			return notifier.OOMKillCount(c.Path)
+		}
+	}
+	return 0, fmt.Errorf("memory subsystem not found")
+}
+
+// Destroy remove the cgroup from every subsystem,the subsystem where it is already gone is skipped
+func (c *CgroupManager) Destroy() error {
+	var errs subsystemErrors
+	for _, subSysIns := range c.subsystems {
+		if err := subSysIns.Remove(c.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs.add(subSysIns.Name(), err)
+		}
+	}
+	return errs.err()
 }
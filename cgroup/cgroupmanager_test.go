@@ -0,0 +1,445 @@
+package cgroup
+
+import (
+	"docker-my/cgroup/subsystem"
+	"docker-my/cgroup/subsystem/fakefs"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+const v1MountInfo = `25 30 0:23 / /sys/fs/cgroup rw,nosuid,nodev,noexec shared:9 - tmpfs tmpfs ro,mode=755
+31 25 0:27 / /sys/fs/cgroup/cpuset rw,nosuid,nodev,noexec,relatime shared:10 - cgroup cgroup rw,cpuset
+32 25 0:28 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,memory
+33 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:12 - cgroup cgroup rw,cpu,cpuacct
+34 25 0:30 / /sys/fs/cgroup/pids rw,nosuid,nodev,noexec,relatime shared:13 - cgroup cgroup rw,pids
+35 25 0:31 / /sys/fs/cgroup/blkio rw,nosuid,nodev,noexec,relatime shared:14 - cgroup cgroup rw,blkio
+36 25 0:32 / /sys/fs/cgroup/freezer rw,nosuid,nodev,noexec,relatime shared:15 - cgroup cgroup rw,freezer
+37 25 0:33 / /sys/fs/cgroup/hugetlb rw,nosuid,nodev,noexec,relatime shared:16 - cgroup cgroup rw,hugetlb
+38 25 0:34 / /sys/fs/cgroup/devices rw,nosuid,nodev,noexec,relatime shared:17 - cgroup cgroup rw,devices
+`
+
+// every v1 cgroup dir get the files of all the controllers,the controllers only read their own
+var v1Files = map[string]string{
+	"tasks":                               "",
+	"cpuset.cpus":                         "0-3",
+	"cpuset.mems":                         "0",
+	"cpuset.effective_cpus":               "0-3",
+	"cpuset.effective_mems":               "0",
+	"memory.limit_in_bytes":               "9223372036854771712",
+	"memory.usage_in_bytes":               "4096",
+	"memory.max_usage_in_bytes":           "8192",
+	"memory.oom_control":                  "oom_kill_disable 0\nunder_oom 0\noom_kill 0",
+	"cpu.shares":                          "1024",
+	"cpuacct.usage":                       "1000",
+	"pids.current":                        "1",
+	"pids.max":                            "max",
+	"blkio.weight":                        "500",
+	"blkio.throttle.io_service_bytes":     "8:0 Read 4096\n8:0 Write 512\nTotal 4608",
+	"freezer.state":                       "THAWED",
+	"hugetlb.2MB.limit_in_bytes":          "9223372036854771712",
+	"hugetlb.2MB.usage_in_bytes":          "0",
+	"hugetlb.2MB.max_usage_in_bytes":      "0",
+	"hugetlb.2MB.failcnt":                 "0",
+	"devices.list":                        "a *:* rwm",
+	"cpu.cfs_period_us":                   "100000",
+	"cpu.cfs_quota_us":                    "-1",
+	"memory.memsw.limit_in_bytes":         "9223372036854771712",
+	"hugetlb.2MB.rsvd.limit_in_bytes":     "9223372036854771712",
+	"blkio.throttle.read_bps_device":      "",
+	"blkio.throttle.write_bps_device":     "",
+	"blkio.throttle.read_iops_device":     "",
+	"blkio.throttle.write_iops_device":    "",
+	"memory.soft_limit_in_bytes":          "9223372036854771712",
+	"cpuset.memory_pressure_enabled":      "0",
+	"cgroup.clone_children":               "0",
+	"notify_on_release":                   "0",
+	"cgroup.procs":                        "",
+	"blkio.throttle.io_serviced":          "Total 0",
+	"memory.swappiness":                   "60",
+	"hugetlb.2MB.rsvd.usage_in_bytes":     "0",
+	"hugetlb.2MB.rsvd.max_usage_in_bytes": "0",
+}
+
+const v2MountInfo = `25 30 0:23 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate
+`
+
+var v2Files = map[string]string{
+	"cgroup.controllers":     "cpuset cpu io memory hugetlb pids",
+	"cgroup.subtree_control": "",
+	"cgroup.procs":           "",
+	"cgroup.events":          "populated 0\nfrozen 0",
+	"cgroup.freeze":          "0",
+	"cpuset.cpus.effective":  "0-3",
+	"cpuset.mems.effective":  "0",
+	"memory.current":         "4096",
+	"memory.max":             "max",
+	"memory.events":          "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0",
+	"cpu.stat":               "usage_usec 5\nuser_usec 3\nsystem_usec 2",
+	"pids.current":           "1",
+	"pids.max":               "max",
+	"io.stat":                "8:0 rbytes=4096 wbytes=512 rios=1 wios=1 dbytes=0 dios=0",
+	"hugetlb.2MB.max":        "max",
+	"hugetlb.2MB.current":    "0",
+	"hugetlb.2MB.events":     "max 0",
+}
+
+// fakeDeviceFilter record the rules instead of attaching a BPF program
+type fakeDeviceFilter struct {
+	attached map[string][]*subsystem.DeviceRule
+}
+
+func (f *fakeDeviceFilter) Attach(cgroupDir string, rules []*subsystem.DeviceRule) error {
+	f.attached[cgroupDir] = rules
+	return nil
+}
+
+// useFakeCgroup replace the cgroup file system and the mountinfo until the test end
+func useFakeCgroup(t *testing.T, mountinfo string, files map[string]string, mountpoints ...string) (*fakefs.FileSystem, *fakeDeviceFilter) {
+	fs := fakefs.New(files)
+	for _, mountpoint := range mountpoints {
+		if err := fs.MkdirAll(mountpoint, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filter := &fakeDeviceFilter{attached: make(map[string][]*subsystem.DeviceRule)}
+	oldFS, oldMountInfo, oldFilter := subsystem.FS, subsystem.OpenMountInfo, subsystem.DeviceFilter
+	subsystem.FS, subsystem.OpenMountInfo, subsystem.DeviceFilter = fs, fakefs.MountInfo(mountinfo), filter
+	t.Cleanup(func() {
+		subsystem.FS, subsystem.OpenMountInfo, subsystem.DeviceFilter = oldFS, oldMountInfo, oldFilter
+	})
+	return fs, filter
+}
+
+func readFile(t *testing.T, fs *fakefs.FileSystem, name string) string {
+	t.Helper()
+	content, err := fs.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func testResources() *subsystem.ResourceConfig {
+	return &subsystem.ResourceConfig{
+		MemoryLimit:  64 * subsystem.MiB,
+		CpuShare:     "512",
+		CpuSet:       "1",
+		PidsLimit:    100,
+		BlkioWeight:  300,
+		HugetlbLimit: []*subsystem.HugepageLimit{{PageSize: "2MB", Limit: 4 * uint64(subsystem.MiB)}},
+		Devices: []*subsystem.Device{{
+			DeviceRule: subsystem.DeviceRule{Type: "c", Major: 10, Minor: 229, Permissions: "rwm"},
+			HostPath:   "/dev/fuse",
+			Path:       "/dev/fuse",
+		}},
+	}
+}
+
+func TestCgroupManagerLifecycleV1(t *testing.T) {
+	hierarchies := []string{"cpuset", "memory", "cpu,cpuacct", "pids", "blkio", "freezer", "hugetlb", "devices"}
+	var mountpoints []string
+	for _, hierarchy := range hierarchies {
+		mountpoints = append(mountpoints, path.Join("/sys/fs/cgroup", hierarchy))
+	}
+	fs, _ := useFakeCgroup(t, v1MountInfo, v1Files, mountpoints...)
+
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if len(manager.unavailable) != 0 {
+		t.Fatalf("unavailable subsystems %v", manager.unavailable)
+	}
+	if err := manager.Set(testResources()); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	dir := func(hierarchy string) string {
+		return path.Join("/sys/fs/cgroup", hierarchy, "mydocker/abc")
+	}
+	for file, want := range map[string]string{
+		path.Join(dir("memory"), "memory.limit_in_bytes"):          "67108864",
+		path.Join(dir("cpu,cpuacct"), "cpu.shares"):                "512",
+		path.Join(dir("cpuset"), "cpuset.cpus"):                    "1",
+		path.Join(dir("pids"), "pids.max"):                         "100",
+		path.Join(dir("blkio"), "blkio.weight"):                    "300",
+		path.Join(dir("hugetlb"), "hugetlb.2MB.limit_in_bytes"):    "4194304",
+		path.Join(dir("devices"), "devices.allow"):                 "c 10:229 rwm",
+		path.Join(dir("devices"), "devices.deny"):                  "a",
+		path.Join("/sys/fs/cgroup/cpuset/mydocker", "cpuset.cpus"): "0-3",
+	} {
+		if got := readFile(t, fs, file); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	if err := manager.Apply(1234); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	for _, hierarchy := range hierarchies {
+		if got := readFile(t, fs, path.Join(dir(hierarchy), "tasks")); got != "1234" {
+			t.Errorf("%s tasks = %q, want 1234", hierarchy, got)
+		}
+	}
+
+	stats, err := manager.GetStats()
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if stats.Memory.Usage != 4096 || stats.Memory.Limit != 64*uint64(subsystem.MiB) || stats.Memory.MaxUsage != 8192 {
+		t.Errorf("memory stats %+v", stats.Memory)
+	}
+	if stats.Cpu.UsageNanos != 1000 || stats.Pids.Current != 1 || stats.Pids.Limit != 100 {
+		t.Errorf("cpu stats %+v,pids stats %+v", stats.Cpu, stats.Pids)
+	}
+	if stats.Blkio.ReadBytes != 4096 || stats.Blkio.WriteBytes != 512 {
+		t.Errorf("blkio stats %+v", stats.Blkio)
+	}
+	if _, ok := stats.Hugetlb["2MB"]; !ok {
+		t.Errorf("hugetlb stats %+v", stats.Hugetlb)
+	}
+
+	if err := manager.Destroy(); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	for _, hierarchy := range hierarchies {
+		if _, err := fs.Stat(dir(hierarchy)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s cgroup is not removed: %v", hierarchy, err)
+		}
+		if _, err := fs.Stat(path.Dir(dir(hierarchy))); err != nil {
+			t.Errorf("%s parent cgroup is removed: %v", hierarchy, err)
+		}
+	}
+	//the cgroup already gone is skipped
+	if err := manager.Destroy(); err != nil {
+		t.Fatalf("destroy again: %v", err)
+	}
+}
+
+func TestCgroupManagerLifecycleV2(t *testing.T) {
+	fs, filter := useFakeCgroup(t, v2MountInfo, v2Files, "/sys/fs/cgroup")
+
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if len(manager.unavailable) != 0 {
+		t.Fatalf("unavailable subsystems %v", manager.unavailable)
+	}
+	res := testResources()
+	res.MemoryHigh = 32 * subsystem.MiB
+	if err := manager.Set(res); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	dir := "/sys/fs/cgroup/mydocker/abc"
+	for file, want := range map[string]string{
+		"memory.max":                "67108864",
+		"memory.high":               "33554432",
+		"cpu.weight":                "20",
+		"cpuset.cpus":               "1",
+		"pids.max":                  "100",
+		"io.weight":                 "default 2930",
+		"hugetlb.2MB.max":           "4194304",
+		"../cgroup.subtree_control": "cpuset memory cpu pids io hugetlb",
+	} {
+		if got := readFile(t, fs, path.Join(dir, file)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+	rules := filter.attached[dir]
+	if len(rules) != len(subsystem.DefaultAllowedDevices)+1 || rules[len(rules)-1].String() != "c 10:229 rwm" {
+		t.Errorf("attached device rules %v", rules)
+	}
+
+	if err := manager.Apply(1234); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got := readFile(t, fs, path.Join(dir, "cgroup.procs")); got != "1234" {
+		t.Errorf("cgroup.procs = %q, want 1234", got)
+	}
+
+	stats, err := manager.GetStats()
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if stats.Memory.Usage != 4096 || stats.Memory.Limit != 64*uint64(subsystem.MiB) {
+		t.Errorf("memory stats %+v", stats.Memory)
+	}
+	if stats.Cpu.UsageNanos != 5000 || stats.Pids.Current != 1 || stats.Pids.Limit != 100 {
+		t.Errorf("cpu stats %+v,pids stats %+v", stats.Cpu, stats.Pids)
+	}
+	if stats.Blkio.ReadBytes != 4096 || stats.Blkio.WriteBytes != 512 {
+		t.Errorf("io stats %+v", stats.Blkio)
+	}
+
+	if err := manager.Destroy(); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	if _, err := fs.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cgroup is not removed: %v", err)
+	}
+	if err := manager.Destroy(); err != nil {
+		t.Fatalf("destroy again: %v", err)
+	}
+}
+
+func TestCgroupManagerSetUnavailable(t *testing.T) {
+	//only the memory hierarchy is mounted
+	useFakeCgroup(t, strings.Split(v1MountInfo, "\n")[0]+"\n"+strings.Split(v1MountInfo, "\n")[2]+"\n", v1Files,
+		"/sys/fs/cgroup/memory")
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	err := manager.Set(&subsystem.ResourceConfig{MemoryLimit: 64 * subsystem.MiB, PidsLimit: 10})
+	if err == nil || !strings.Contains(err.Error(), "pids: subsystem is not available") {
+		t.Fatalf("set error %v,want pids not available", err)
+	}
+	if err := manager.Set(&subsystem.ResourceConfig{MemoryLimit: 64 * subsystem.MiB}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+}
+
+func TestCgroupManagerGetStatsPartial(t *testing.T) {
+	fs, _ := useFakeCgroup(t, v2MountInfo, v2Files, "/sys/fs/cgroup")
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if err := manager.Set(&subsystem.ResourceConfig{PidsLimit: 100}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := fs.Remove("/sys/fs/cgroup/mydocker/abc/memory.current"); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := manager.GetStats()
+	if err == nil || !strings.Contains(err.Error(), "memory: ") {
+		t.Fatalf("get stats error %v,want the memory error", err)
+	}
+	if stats == nil {
+		t.Fatal("the stats of the other subsystems should still be returned")
+	}
+	if stats.Cpu.UsageNanos != 5000 || stats.Pids.Current != 1 || stats.Blkio.ReadBytes != 4096 {
+		t.Errorf("partial stats cpu %+v,pids %+v,io %+v", stats.Cpu, stats.Pids, stats.Blkio)
+	}
+}
+
+func TestCgroupManagerApplyPartialRollback(t *testing.T) {
+	hierarchies := []string{"cpuset", "memory", "cpu,cpuacct", "pids", "blkio", "freezer", "hugetlb", "devices"}
+	var mountpoints []string
+	for _, hierarchy := range hierarchies {
+		mountpoints = append(mountpoints, path.Join("/sys/fs/cgroup", hierarchy))
+	}
+	fs, _ := useFakeCgroup(t, v1MountInfo, v1Files, mountpoints...)
+	dir := func(hierarchy string) string {
+		return path.Join("/sys/fs/cgroup", hierarchy, "mydocker/abc")
+	}
+
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if err := manager.Set(testResources()); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	//the memory cgroup is gone,the process can't be added to it but the other subsystems still get it
+	if err := fs.Remove(dir("memory")); err != nil {
+		t.Fatal(err)
+	}
+	err := manager.Apply(1234)
+	if err == nil {
+		t.Fatal("apply with a missing memory cgroup succeeded")
+	}
+	if !strings.HasPrefix(err.Error(), "memory: ") || strings.Contains(err.Error(), ";") {
+		t.Errorf("apply error %q,want only the memory error", err)
+	}
+	if got := readFile(t, fs, path.Join(dir("pids"), "tasks")); got != "1234" {
+		t.Errorf("pids tasks = %q, want 1234", got)
+	}
+
+	//the rollback remove the partially applied cgroup from every hierarchy
+	if err := manager.Destroy(); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	for _, hierarchy := range hierarchies {
+		if _, err := fs.Stat(dir(hierarchy)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s cgroup is not rolled back: %v", hierarchy, err)
+		}
+	}
+}
+
+// useFreezeTimeout shorten the wait of the freezer until the test end
+func useFreezeTimeout(t *testing.T, timeout time.Duration) {
+	old := subsystem.FreezeTimeout
+	subsystem.FreezeTimeout = timeout
+	t.Cleanup(func() { subsystem.FreezeTimeout = old })
+}
+
+func TestCgroupManagerWaitFreezerStateV2(t *testing.T) {
+	useFreezeTimeout(t, 50*time.Millisecond)
+	fs, _ := useFakeCgroup(t, v2MountInfo, v2Files, "/sys/fs/cgroup")
+	dir := "/sys/fs/cgroup/mydocker/abc"
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if err := manager.Set(&subsystem.ResourceConfig{}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	if err := manager.SetFreezerState(subsystem.Frozen); err != nil {
+		t.Fatalf("set freezer state: %v", err)
+	}
+	if got := readFile(t, fs, path.Join(dir, "cgroup.freeze")); got != "1" {
+		t.Errorf("cgroup.freeze = %q, want 1", got)
+	}
+	//the freeze is asked but cgroup.events doesn't report it yet
+	if state, err := manager.GetFreezerState(); err != nil || state != subsystem.Freezing {
+		t.Fatalf("freezer state %s,%v,want %s", state, err, subsystem.Freezing)
+	}
+	if err := manager.WaitFreezerState(subsystem.Frozen, nil); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("wait an unsettled freeze error %v,want a timeout", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		fs.WriteFile(path.Join(dir, "cgroup.events"), []byte("populated 1\nfrozen 1"), 0644)
+	}()
+	if err := manager.WaitFreezerState(subsystem.Frozen, nil); err != nil {
+		t.Fatalf("wait freeze: %v", err)
+	}
+
+	//a thaw done by someone else while waiting for the freeze is not waited until the timeout
+	if err := manager.SetFreezerState(subsystem.Thawed); err != nil {
+		t.Fatal(err)
+	}
+	fs.WriteFile(path.Join(dir, "cgroup.events"), []byte("populated 1\nfrozen 0"), 0644)
+	if err := manager.WaitFreezerState(subsystem.Frozen, nil); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("wait a freeze of a thawed cgroup error %v", err)
+	}
+}
+
+func TestCgroupManagerWaitFreezerStateRetryV1(t *testing.T) {
+	useFreezeTimeout(t, time.Second)
+	fs, _ := useFakeCgroup(t, v1MountInfo, v1Files, "/sys/fs/cgroup/freezer")
+	stateFile := "/sys/fs/cgroup/freezer/mydocker/abc/freezer.state"
+	manager := NewCGroupManager(ContainerCgroupPath("", "abc"))
+	if err := manager.Set(&subsystem.ResourceConfig{}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	//a freeze interrupted by a new process stay FREEZING until the state is written again
+	fs.WriteFile(stateFile, []byte("FREEZING"), 0644)
+	retries := 0
+	err := manager.WaitFreezerState(subsystem.Frozen, func() error {
+		retries++
+		if retries == 3 {
+			return manager.SetFreezerState(subsystem.Frozen)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("wait freeze: %v", err)
+	}
+	if retries != 3 {
+		t.Errorf("retried %d times,want 3", retries)
+	}
+
+	fs.WriteFile(stateFile, []byte("FREEZING"), 0644)
+	retryErr := errors.New("not paused anymore")
+	if err := manager.WaitFreezerState(subsystem.Frozen, func() error { return retryErr }); err != retryErr {
+		t.Fatalf("wait freeze error %v,want the retry error", err)
+	}
+
+	if err := manager.Freeze(subsystem.Thawed); err != nil {
+		t.Fatalf("thaw: %v", err)
+	}
+	if got := readFile(t, fs, stateFile); got != "THAWED" {
+		t.Errorf("freezer.state = %q, want THAWED", got)
+	}
+}
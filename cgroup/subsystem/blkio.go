@@ -0,0 +1,187 @@
+package subsystem
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// WeightDevice is the relative weight of one block device
+type WeightDevice struct {
+	Major  int64  `json:"major"`
+	Minor  int64  `json:"minor"`
+	Weight uint16 `json:"weight"`
+}
+
+// ThrottleDevice is the bytes or io per second limit of one block device
+type ThrottleDevice struct {
+	Major int64  `json:"major"`
+	Minor int64  `json:"minor"`
+	Rate  uint64 `json:"rate"`
+}
+
+func (d *WeightDevice) String() string {
+	return fmt.Sprintf("%d:%d %d", d.Major, d.Minor, d.Weight)
+}
+
+func (d *ThrottleDevice) String() string {
+	return fmt.Sprintf("%d:%d %d", d.Major, d.Minor, d.Rate)
+}
+
+type BlkioSubSystem struct {
+}
+
+func (s *BlkioSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.BlkioWeight != 0 {
+		//the kernel with the bfq scheduler only provide the blkio.bfq.weight
+		weightFile := "blkio.weight"
+		if _, err := FS.Stat(path.Join(subsysCgroupPath, weightFile)); os.IsNotExist(err) {
+			weightFile = "blkio.bfq.weight"
+		}
+		if err := writeCgroupFile(subsysCgroupPath, weightFile, strconv.Itoa(int(res.BlkioWeight))); err != nil {
+			return fmt.Errorf("set cgroup blkio weight fail %v", err)
+		}
+	}
+	for _, wd := range res.BlkioWeightDevice {
+		if err := writeCgroupFile(subsysCgroupPath, "blkio.weight_device", wd.String()); err != nil {
+			return fmt.Errorf("set cgroup blkio weight device fail %v", err)
+		}
+	}
+	throttles := map[string][]*ThrottleDevice{
+		"blkio.throttle.read_bps_device":   res.BlkioDeviceReadBps,
+		"blkio.throttle.write_bps_device":  res.BlkioDeviceWriteBps,
+		"blkio.throttle.read_iops_device":  res.BlkioDeviceReadIOps,
+		"blkio.throttle.write_iops_device": res.BlkioDeviceWriteIOps,
+	}
+	for file, devices := range throttles {
+		//the kernel only accept one device every write
+		for _, td := range devices {
+			if err := writeCgroupFile(subsysCgroupPath, file, td.String()); err != nil {
+				return fmt.Errorf("set cgroup %s fail %v", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+func (s *BlkioSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	//every line is like "8:0 Read 4096",the last line is the "Total"
+	content, err := readCgroupFile(subsysCgroupPath, "blkio.throttle.io_service_bytes")
+	if err != nil {
+		return fmt.Errorf("read blkio io service bytes fail %v", err)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			stats.Blkio.ReadBytes += value
+		case "Write":
+			stats.Blkio.WriteBytes += value
+		}
+	}
+	return nil
+}
+
+func (s *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+// ParseWeightDevice parse the "/dev/sda:200" into the weight of the device
+func ParseWeightDevice(spec string) (*WeightDevice, error) {
+	devicePath, value, err := splitDeviceSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	weight, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || weight < 10 || weight > 1000 {
+		return nil, fmt.Errorf("invalid weight %s for device %s,the range is from 10 to 1000", value, devicePath)
+	}
+	major, minor, err := blockDeviceNumber(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	return &WeightDevice{Major: major, Minor: minor, Weight: uint16(weight)}, nil
+}
+
+// ParseThrottleDevice parse the "/dev/sda:1048576" into the rate limit of the device,
+// the bytes per second rate accept the units like "/dev/sda:10mb"
+func ParseThrottleDevice(spec string, withUnit bool) (*ThrottleDevice, error) {
+	devicePath, value, err := splitDeviceSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	var rate uint64
+	if withUnit {
+		bytes, err := RAMInBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate %s for device %s", value, devicePath)
+		}
+		rate = uint64(bytes)
+	} else if rate, err = strconv.ParseUint(value, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid rate %s for device %s", value, devicePath)
+	}
+	major, minor, err := blockDeviceNumber(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ThrottleDevice{Major: major, Minor: minor, Rate: rate}, nil
+}
+
+func splitDeviceSpec(spec string) (string, string, error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || i == len(spec)-1 {
+		return "", "", fmt.Errorf("invalid device spec %s,the format is <device-path>:<value>", spec)
+	}
+	return spec[:i], spec[i+1:], nil
+}
+
+// resolve the path of a block device to its major:minor
+func blockDeviceNumber(devicePath string) (int64, int64, error) {
+	info, err := os.Stat(devicePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat device %s error %v", devicePath, err)
+	}
+	if info.Mode()&os.ModeDevice == 0 || info.Mode()&os.ModeCharDevice != 0 {
+		return 0, 0, fmt.Errorf("%s is not a block device", devicePath)
+	}
+	rdev := uint64(info.Sys().(*syscall.Stat_t).Rdev)
+	return int64(unix.Major(rdev)), int64(unix.Minor(rdev)), nil
+}
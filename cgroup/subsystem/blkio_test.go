@@ -0,0 +1,118 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlkioWeightToIOWeight(t *testing.T) {
+	tests := []struct {
+		weight, ioWeight uint64
+	}{
+		{0, 1},
+		{10, 1},
+		{500, 4950},
+		{1000, 10000},
+	}
+	for _, test := range tests {
+		if got := blkioWeightToIOWeight(test.weight); got != test.ioWeight {
+			t.Errorf("weight %d = io weight %d,want %d", test.weight, got, test.ioWeight)
+		}
+	}
+}
+
+func TestIoSubSystemV2Set(t *testing.T) {
+	fs := &recordingFS{FileSystem: useFakeCgroupV2(t, nil)}
+	FS = fs
+	res := &ResourceConfig{
+		BlkioWeight:          500,
+		BlkioWeightDevice:    []*WeightDevice{{Major: 8, Minor: 0, Weight: 1000}},
+		BlkioDeviceReadBps:   []*ThrottleDevice{{Major: 8, Minor: 0, Rate: 1048576}},
+		BlkioDeviceWriteBps:  []*ThrottleDevice{{Major: 8, Minor: 16, Rate: 2048}},
+		BlkioDeviceWriteIOps: []*ThrottleDevice{{Major: 8, Minor: 0, Rate: 100}},
+	}
+	if err := (&IoSubSystemV2{}).Set("mydocker/abc", res); err != nil {
+		t.Fatal(err)
+	}
+	var ioWrites []string
+	for _, write := range fs.writes {
+		if strings.HasPrefix(write, "io.") {
+			ioWrites = append(ioWrites, write)
+		}
+	}
+	//all the limits of one device are in one line,the devices keep the order of the flags
+	want := []string{
+		"io.weight default 4950",
+		"io.weight 8:0 10000",
+		"io.max 8:0 rbps=1048576 wiops=100",
+		"io.max 8:16 wbps=2048",
+	}
+	if !reflect.DeepEqual(ioWrites, want) {
+		t.Errorf("writes %q,want %q", ioWrites, want)
+	}
+}
+
+func TestIoSubSystemV2GetStats(t *testing.T) {
+	useFakeCgroupV2(t, map[string]string{
+		"io.stat": "8:0 rbytes=4096 wbytes=512 rios=1 wios=1 dbytes=0 dios=0\n8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0",
+	})
+	if _, err := GetCgroupV2Path("io", "mydocker/abc", true); err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	if err := (&IoSubSystemV2{}).GetStats("mydocker/abc", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Blkio.ReadBytes != 5120 || stats.Blkio.WriteBytes != 512 {
+		t.Errorf("blkio stats %+v", stats.Blkio)
+	}
+}
+
+func TestBlkioSubSystemSet(t *testing.T) {
+	//the kernel with the bfq scheduler has no blkio.weight
+	fs := &recordingFS{FileSystem: fakefs.New(map[string]string{"blkio.bfq.weight": "100", "tasks": ""})}
+	if err := fs.MkdirAll("/sys/fs/cgroup/blkio", 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldFS, oldMountInfo := FS, OpenMountInfo
+	FS = fs
+	OpenMountInfo = fakefs.MountInfo("35 25 0:31 / /sys/fs/cgroup/blkio rw,nosuid,nodev,noexec,relatime shared:14 - cgroup cgroup rw,blkio\n")
+	t.Cleanup(func() {
+		FS, OpenMountInfo = oldFS, oldMountInfo
+	})
+	res := &ResourceConfig{
+		BlkioWeight:         300,
+		BlkioDeviceReadBps:  []*ThrottleDevice{{Major: 8, Minor: 0, Rate: 1048576}, {Major: 8, Minor: 16, Rate: 4096}},
+		BlkioDeviceReadIOps: []*ThrottleDevice{{Major: 8, Minor: 0, Rate: 100}},
+	}
+	if err := (&BlkioSubSystem{}).Set("mydocker/abc", res); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"blkio.bfq.weight 300":                       true,
+		"blkio.throttle.read_bps_device 8:0 1048576": true,
+		"blkio.throttle.read_bps_device 8:16 4096":   true,
+		"blkio.throttle.read_iops_device 8:0 100":    true,
+	}
+	if len(fs.writes) != len(want) {
+		t.Errorf("writes %q", fs.writes)
+	}
+	for _, write := range fs.writes {
+		if !want[write] {
+			t.Errorf("unexpected write %q", write)
+		}
+	}
+}
+
+func TestParseThrottleDevice(t *testing.T) {
+	for _, spec := range []string{"", "/dev/sda", ":100", "/dev/sda:", "/dev/null:100", "/nonexistent:100"} {
+		if _, err := ParseThrottleDevice(spec, true); err == nil {
+			t.Errorf("%q: want error", spec)
+		}
+	}
+	if _, err := ParseWeightDevice("/dev/null:5"); err == nil || !strings.Contains(err.Error(), "range") {
+		t.Errorf("weight out of range error %v", err)
+	}
+}
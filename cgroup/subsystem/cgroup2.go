@@ -0,0 +1,227 @@
+package subsystem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// SubSystemV2Ins is used instead of SubSystemIns on the host only mount the unified hierarchy
+	SubSystemV2Ins = []SubSystem{
+		&CpusetSubSystemV2{},
+		&MemorySubSystemV2{},
+		&CpuSubSystemV2{},
+		&PidsSubSystemV2{},
+		&IoSubSystemV2{},
+		&FreezerSubSystemV2{},
+		&HugetlbSubSystemV2{},
+		&DevicesSubSystemV2{},
+	}
+)
+
+// find the mountpoint of the cgroup2 file system by the mountinfo
+func FindCgroup2Mountpoint() string {
+	f, err := OpenMountInfo()
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fsType, mountpoint := mountFsType(scanner.Text()); fsType == "cgroup2" {
+			return rootedMountpoint(mountpoint)
+		}
+	}
+	return ""
+}
+
+// IsCgroup2UnifiedMode report whether the host only mount the cgroup2 file system,
+// a hybrid host which still mount the v1 controllers is treated as v1
+func IsCgroup2UnifiedMode() bool {
+	f, err := OpenMountInfo()
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	unified := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		switch fsType, _ := mountFsType(scanner.Text()); fsType {
+		case "cgroup":
+			return false
+		case "cgroup2":
+			unified = true
+		}
+	}
+	return unified
+}
+
+// return the file system type and the mountpoint of one mountinfo line,
+// the optional fields end with a single "-" before the file system type
+func mountFsType(line string) (string, string) {
+	fields := strings.Split(line, " ")
+	if len(fields) < 5 {
+		return "", ""
+	}
+	for i := 6; i < len(fields)-1; i++ {
+		if fields[i] == "-" {
+			return fields[i+1], fields[4]
+		}
+	}
+	return "", fields[4]
+}
+
+// CgroupMount is one cgroup hierarchy mounted on the host
+type CgroupMount struct {
+	Mountpoint string
+	FsType     string
+	// the controllers bound to a v1 hierarchy,such as "cpu" or "name=systemd"
+	Controllers []string
+}
+
+// CgroupMounts return all the cgroup and cgroup2 file systems in the mountinfo
+func CgroupMounts() ([]CgroupMount, error) {
+	f, err := OpenMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []CgroupMount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		txt := scanner.Text()
+		fsType, mountpoint := mountFsType(txt)
+		if fsType != "cgroup" && fsType != "cgroup2" {
+			continue
+		}
+		mount := CgroupMount{Mountpoint: mountpoint, FsType: fsType}
+		if fsType == "cgroup" {
+			fields := strings.Split(txt, " ")
+			for _, opt := range strings.Split(fields[len(fields)-1], ",") {
+				//the super options also contain the flags of the file system
+				if opt == "rw" || opt == "ro" || strings.Contains(opt, "=") && !strings.HasPrefix(opt, "name=") ||
+					opt == "xattr" || opt == "noprefix" || opt == "clone_children" || opt == "cpuset_v2_mode" {
+					continue
+				}
+				mount.Controllers = append(mount.Controllers, opt)
+			}
+		}
+		mounts = append(mounts, mount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// Available report whether the subsystem can be used on the host,the v1 hierarchy
+// must be mounted and the v2 controller must be offered by the root cgroup
+func Available(subsystem SubSystem) bool {
+	if !IsCgroup2UnifiedMode() {
+		return FindCgroupMountpoint(subsystem.Name()) != ""
+	}
+	switch subsystem.(type) {
+	//the freezer is part of the core and the devices are controlled by the bpf program
+	case *FreezerSubSystemV2, *DevicesSubSystemV2:
+		return true
+	}
+	cgroupRoot := FindCgroup2Mountpoint()
+	if cgroupRoot == "" {
+		return false
+	}
+	available, err := readCgroupFile(cgroupRoot, "cgroup.controllers")
+	if err != nil {
+		return false
+	}
+	return containsField(available, subsystem.Name())
+}
+
+// get the absolute path in the unified hierarchy,the controller is enabled
+// in the cgroup.subtree_control of every ancestor when the path is auto created
+func GetCgroupV2Path(controller string, cgroupPath string, autoCreate bool) (string, error) {
+	cgroupRoot := FindCgroup2Mountpoint()
+	if cgroupRoot == "" {
+		return "", fmt.Errorf("cgroup2 mountpoint not found")
+	}
+	absPath := path.Join(cgroupRoot, cgroupPath)
+	if _, err := FS.Stat(absPath); err != nil {
+		if !os.IsNotExist(err) || !autoCreate {
+			return "", fmt.Errorf("cgroup path error %w", err)
+		}
+		if err := FS.MkdirAll(absPath, 0755); err != nil {
+			return "", fmt.Errorf("error create cgroup %v", err)
+		}
+	}
+	if autoCreate && controller != "" {
+		if err := enableController(cgroupRoot, cgroupPath, controller); err != nil {
+			return "", err
+		}
+	}
+	return absPath, nil
+}
+
+// write "+controller" to the cgroup.subtree_control from the root down to the parent of cgroupPath
+func enableController(cgroupRoot, cgroupPath, controller string) error {
+	available, err := readCgroupFile(cgroupRoot, "cgroup.controllers")
+	if err != nil {
+		return fmt.Errorf("read cgroup controllers fail %v", err)
+	}
+	if !containsField(available, controller) {
+		return fmt.Errorf("cgroup controller %s is not available", controller)
+	}
+	dir := cgroupRoot
+	for _, elem := range strings.Split(path.Dir(path.Clean("/"+cgroupPath)), "/") {
+		dir = path.Join(dir, elem)
+		enabled, err := readCgroupFile(dir, "cgroup.subtree_control")
+		if err != nil {
+			return fmt.Errorf("read subtree control of %s fail %v", dir, err)
+		}
+		if containsField(enabled, controller) {
+			continue
+		}
+		if err := writeCgroupFile(dir, "cgroup.subtree_control", "+"+controller); err != nil {
+			return fmt.Errorf("enable controller %s in %s fail %v", controller, dir, err)
+		}
+	}
+	return nil
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// add the process to the unified cgroup,every controller share the same cgroup.procs
+func applyV2(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupV2Path("", cgroupPath, false)
+	if err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+	if err := writeCgroupFile(subsysCgroupPath, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v", err)
+	}
+	return nil
+}
+
+// remove the unified cgroup,it is already gone when another controller removed it
+func removeV2(cgroupPath string) error {
+	cgroupRoot := FindCgroup2Mountpoint()
+	if cgroupRoot == "" {
+		return fmt.Errorf("cgroup2 mountpoint not found")
+	}
+	if err := FS.Remove(path.Join(cgroupRoot, cgroupPath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
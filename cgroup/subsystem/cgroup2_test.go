@@ -0,0 +1,153 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"io"
+	"testing"
+)
+
+const testCgroup2MountInfo = "25 30 0:23 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate\n"
+
+// useFakeCgroupV2 put the unified hierarchy in memory,every new cgroup get the files
+func useFakeCgroupV2(t *testing.T, files map[string]string) *fakefs.FileSystem {
+	defaults := map[string]string{
+		"cgroup.controllers":     "cpuset cpu io memory hugetlb pids",
+		"cgroup.subtree_control": "",
+		"cgroup.procs":           "",
+	}
+	for file, content := range files {
+		defaults[file] = content
+	}
+	fs := fakefs.New(defaults)
+	if err := fs.MkdirAll("/sys/fs/cgroup", 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldFS, oldMountInfo := FS, OpenMountInfo
+	FS, OpenMountInfo = fs, fakefs.MountInfo(testCgroup2MountInfo)
+	t.Cleanup(func() {
+		FS, OpenMountInfo = oldFS, oldMountInfo
+	})
+	return fs
+}
+
+func readTestFile(t *testing.T, fs *fakefs.FileSystem, name string) string {
+	t.Helper()
+	content, err := fs.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestIsCgroup2UnifiedMode(t *testing.T) {
+	oldMountInfo := OpenMountInfo
+	t.Cleanup(func() { OpenMountInfo = oldMountInfo })
+	tests := []struct {
+		name      string
+		mountinfo string
+		unified   bool
+	}{
+		{"unified", testCgroup2MountInfo, true},
+		{"v1", "32 25 0:28 / /sys/fs/cgroup/memory rw,nosuid shared:11 - cgroup cgroup rw,memory\n", false},
+		//the hybrid host mount the unified hierarchy beside the v1 controllers
+		{"hybrid", "26 25 0:24 / /sys/fs/cgroup/unified rw,nosuid shared:5 - cgroup2 cgroup2 rw\n" +
+			"32 25 0:28 / /sys/fs/cgroup/memory rw,nosuid shared:11 - cgroup cgroup rw,memory\n", false},
+		{"none", "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n", false},
+	}
+	for _, test := range tests {
+		OpenMountInfo = fakefs.MountInfo(test.mountinfo)
+		if got := IsCgroup2UnifiedMode(); got != test.unified {
+			t.Errorf("%s: unified %v,want %v", test.name, got, test.unified)
+		}
+	}
+	OpenMountInfo = func() (io.ReadCloser, error) { return nil, io.ErrUnexpectedEOF }
+	if IsCgroup2UnifiedMode() {
+		t.Error("unreadable mountinfo is unified")
+	}
+}
+
+func TestGetCgroupV2PathEnableController(t *testing.T) {
+	fs := useFakeCgroupV2(t, nil)
+	if _, err := GetCgroupV2Path("memory", "mydocker/abc", false); err == nil {
+		t.Error("missing cgroup without auto create succeeded")
+	}
+	dir, err := GetCgroupV2Path("memory", "mydocker/abc", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/sys/fs/cgroup/mydocker/abc" {
+		t.Errorf("cgroup dir %s", dir)
+	}
+	//the controller is enabled in every ancestor,not in the cgroup itself
+	for file, want := range map[string]string{
+		"/sys/fs/cgroup/cgroup.subtree_control":              "memory",
+		"/sys/fs/cgroup/mydocker/cgroup.subtree_control":     "memory",
+		"/sys/fs/cgroup/mydocker/abc/cgroup.subtree_control": "",
+	} {
+		if got := readTestFile(t, fs, file); got != want {
+			t.Errorf("%s = %q,want %q", file, got, want)
+		}
+	}
+	if _, err := GetCgroupV2Path("pids", "mydocker/abc", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/cgroup.subtree_control"); got != "memory pids" {
+		t.Errorf("subtree control %q,want \"memory pids\"", got)
+	}
+	if _, err := GetCgroupV2Path("rdma", "mydocker/abc", true); err == nil {
+		t.Error("enable an unavailable controller succeeded")
+	}
+}
+
+func TestCpuSharesToWeight(t *testing.T) {
+	tests := []struct {
+		shares, weight uint64
+	}{
+		{0, 1},
+		{2, 1},
+		{512, 20},
+		{1024, 39},
+		{262144, 10000},
+		{1000000, 10000},
+	}
+	for _, test := range tests {
+		if got := cpuSharesToWeight(test.shares); got != test.weight {
+			t.Errorf("shares %d = weight %d,want %d", test.shares, got, test.weight)
+		}
+	}
+}
+
+func TestSubSystemV2Set(t *testing.T) {
+	fs := useFakeCgroupV2(t, nil)
+	res := &ResourceConfig{MemoryLimit: -1, CpuShare: "1024"}
+	for _, subSysIns := range []SubSystem{&MemorySubSystemV2{}, &CpuSubSystemV2{}} {
+		if err := subSysIns.Set("mydocker/abc", res); err != nil {
+			t.Fatalf("%s: %v", subSysIns.Name(), err)
+		}
+	}
+	dir := "/sys/fs/cgroup/mydocker/abc/"
+	for file, want := range map[string]string{
+		"memory.max": "max",
+		"cpu.weight": "39",
+	} {
+		if got := readTestFile(t, fs, dir+file); got != want {
+			t.Errorf("%s = %q,want %q", file, got, want)
+		}
+	}
+	if _, err := fs.ReadFile(dir + "cpu.max"); err == nil {
+		t.Error("cpu.max is written without a quota or period")
+	}
+	if err := (&MemorySubSystemV2{}).Apply("mydocker/abc", 1234); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, dir+"cgroup.procs"); got != "1234" {
+		t.Errorf("cgroup.procs = %q,want 1234", got)
+	}
+	if err := (&CpuSubSystemV2{}).Remove("mydocker/abc"); err != nil {
+		t.Fatal(err)
+	}
+	//the unified cgroup is already removed by another controller
+	if err := (&MemorySubSystemV2{}).Remove("mydocker/abc"); err != nil {
+		t.Errorf("remove a gone cgroup: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package subsystem
+
+import (
+	"path"
+	"strings"
+)
+
+// write the value to the file of the cgroup dir
+func writeCgroupFile(cgroupDir, file, value string) error {
+	return FS.WriteFile(path.Join(cgroupDir, file), []byte(value), 0644)
+}
+
+// read the file of the cgroup dir,the trailing newline is trimmed
+func readCgroupFile(cgroupDir, file string) (string, error) {
+	content, err := FS.ReadFile(path.Join(cgroupDir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
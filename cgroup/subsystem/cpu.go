@@ -2,7 +2,6 @@ package subsystem
 
 import (
 	"fmt"
-	"os"
 	"path"
 	"strconv"
 )
@@ -13,10 +12,20 @@ type CpuSubSystem struct {
 func (s *CpuSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
 		if res.CpuShare != "" {
-			if err := os.WriteFile(path.Join(subsysCgroupPath, "cpu.shares"), []byte(res.CpuShare), 0644); err != nil {
+			if err := FS.WriteFile(path.Join(subsysCgroupPath, "cpu.shares"), []byte(res.CpuShare), 0644); err != nil {
 				return fmt.Errorf("set cgroup cpu share fail %v", err)
 			}
 		}
+		if res.CpuPeriod != 0 {
+			if err := writeCgroupFile(subsysCgroupPath, "cpu.cfs_period_us", strconv.FormatUint(res.CpuPeriod, 10)); err != nil {
+				return fmt.Errorf("set cgroup cpu period fail %v", err)
+			}
+		}
+		if res.CpuQuota != 0 {
+			if err := writeCgroupFile(subsysCgroupPath, "cpu.cfs_quota_us", strconv.FormatInt(res.CpuQuota, 10)); err != nil {
+				return fmt.Errorf("set cgroup cpu quota fail %v", err)
+			}
+		}
 		return nil
 	} else {
 		return err
@@ -25,7 +34,7 @@ func (s *CpuSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 
 func (s *CpuSubSystem) Remove(cgroupPath string) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		return os.RemoveAll(subsysCgroupPath)
+		return FS.RemoveAll(subsysCgroupPath)
 	} else {
 		return err
 	}
@@ -33,7 +42,7 @@ func (s *CpuSubSystem) Remove(cgroupPath string) error {
 
 func (s *CpuSubSystem) Apply(cgroupPath string, pid int) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		if err := os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -45,3 +54,6 @@ func (s *CpuSubSystem) Apply(cgroupPath string, pid int) error {
 func (s *CpuSubSystem) Name() string {
 	return "cpu"
 }
+
+// DefaultCpuPeriod is the cfs period used by the kernel when it is not set
+const DefaultCpuPeriod uint64 = 100000
@@ -0,0 +1,58 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"testing"
+)
+
+func TestCpuSubSystemV2Max(t *testing.T) {
+	fs := useFakeCgroupV2(t, nil)
+	tests := []struct {
+		res  ResourceConfig
+		want string
+	}{
+		{ResourceConfig{CpuPeriod: DefaultCpuPeriod, CpuQuota: 150000}, "150000 100000"},
+		//the default period is used when only the quota is given
+		{ResourceConfig{CpuQuota: 50000}, "50000 100000"},
+		{ResourceConfig{CpuPeriod: 200000}, "max 200000"},
+		{ResourceConfig{CpuQuota: -1}, "max 100000"},
+	}
+	for _, test := range tests {
+		if err := (&CpuSubSystemV2{}).Set("mydocker/abc", &test.res); err != nil {
+			t.Fatal(err)
+		}
+		if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/abc/cpu.max"); got != test.want {
+			t.Errorf("%+v: cpu.max = %q,want %q", test.res, got, test.want)
+		}
+	}
+}
+
+func TestCpuSubSystemQuota(t *testing.T) {
+	fs := fakefs.New(map[string]string{"cpu.cfs_period_us": "100000", "cpu.cfs_quota_us": "-1", "tasks": ""})
+	if err := fs.MkdirAll("/sys/fs/cgroup/cpu,cpuacct", 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldFS, oldMountInfo := FS, OpenMountInfo
+	FS = fs
+	OpenMountInfo = fakefs.MountInfo("33 25 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:12 - cgroup cgroup rw,cpu,cpuacct\n")
+	t.Cleanup(func() {
+		FS, OpenMountInfo = oldFS, oldMountInfo
+	})
+	if err := (&CpuSubSystem{}).Set("mydocker/abc", &ResourceConfig{CpuPeriod: 50000, CpuQuota: 75000}); err != nil {
+		t.Fatal(err)
+	}
+	dir := "/sys/fs/cgroup/cpu,cpuacct/mydocker/abc/"
+	if got := readTestFile(t, fs, dir+"cpu.cfs_period_us"); got != "50000" {
+		t.Errorf("cpu.cfs_period_us = %q,want 50000", got)
+	}
+	if got := readTestFile(t, fs, dir+"cpu.cfs_quota_us"); got != "75000" {
+		t.Errorf("cpu.cfs_quota_us = %q,want 75000", got)
+	}
+	//the quota is lifted by update
+	if err := (&CpuSubSystem{}).Set("mydocker/abc", &ResourceConfig{CpuQuota: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, dir+"cpu.cfs_quota_us"); got != "-1" {
+		t.Errorf("cpu.cfs_quota_us = %q,want -1", got)
+	}
+}
@@ -0,0 +1,81 @@
+package subsystem
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CpuSubSystemV2 is the cpu controller of the unified hierarchy
+type CpuSubSystemV2 struct {
+}
+
+func (s *CpuSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.CpuShare != "" {
+		shares, err := strconv.ParseUint(res.CpuShare, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpu share %s %v", res.CpuShare, err)
+		}
+		weight := strconv.FormatUint(cpuSharesToWeight(shares), 10)
+		if err := writeCgroupFile(subsysCgroupPath, "cpu.weight", weight); err != nil {
+			return fmt.Errorf("set cgroup cpu weight fail %v", err)
+		}
+	}
+	if res.CpuQuota != 0 || res.CpuPeriod != 0 {
+		//cpu.max is "$QUOTA $PERIOD",the quota is "max" when no limit
+		period := res.CpuPeriod
+		if period == 0 {
+			period = DefaultCpuPeriod
+		}
+		quota := "max"
+		if res.CpuQuota > 0 {
+			quota = strconv.FormatInt(res.CpuQuota, 10)
+		}
+		if err := writeCgroupFile(subsysCgroupPath, "cpu.max", fmt.Sprintf("%s %d", quota, period)); err != nil {
+			return fmt.Errorf("set cgroup cpu max fail %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *CpuSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *CpuSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *CpuSubSystemV2) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	cpuStat, err := readCgroupKeyValues(subsysCgroupPath, "cpu.stat")
+	if err != nil {
+		return err
+	}
+	stats.Cpu.UsageNanos = cpuStat["usage_usec"] * 1000
+	if stats.Cpu.Pressure, err = readPressure(subsysCgroupPath, "cpu.pressure"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *CpuSubSystemV2) Name() string {
+	return "cpu"
+}
+
+// map the v1 cpu.shares [2,262144] to the v2 cpu.weight [1,10000]
+func cpuSharesToWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
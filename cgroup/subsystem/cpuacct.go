@@ -0,0 +1,51 @@
+package subsystem
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+)
+
+// CpuacctSubSystem only account the cpu time used by the cgroup
+type CpuacctSubSystem struct {
+}
+
+func (s *CpuacctSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *CpuacctSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *CpuacctSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+func (s *CpuacctSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	//cpuacct.usage is the total cpu time in nanoseconds
+	if stats.Cpu.UsageNanos, err = readCgroupUint(subsysCgroupPath, "cpuacct.usage"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
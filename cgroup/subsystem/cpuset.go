@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type CpusetSubSystem struct {
@@ -12,11 +14,27 @@ type CpusetSubSystem struct {
 
 func (s *CpusetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		//a new cpuset cgroup start with empty cpus and mems,and any task can not join it
+		if err := inheritCpuset(FindCgroupMountpoint(s.Name()), cgroupPath); err != nil {
+			return err
+		}
+		parentPath := path.Dir(subsysCgroupPath)
 		if res.CpuSet != "" {
-			if err := os.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), 0644); err != nil {
+			if err := checkCpusetAvailable(res.CpuSet, parentPath, "cpuset.effective_cpus", "cpuset.cpus", "cpu"); err != nil {
+				return err
+			}
+			if err := FS.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), 0644); err != nil {
 				return fmt.Errorf("set cgroup cpuset fail %v", err)
 			}
 		}
+		if res.CpuSetMems != "" {
+			if err := checkCpusetAvailable(res.CpuSetMems, parentPath, "cpuset.effective_mems", "cpuset.mems", "memory node"); err != nil {
+				return err
+			}
+			if err := writeCgroupFile(subsysCgroupPath, "cpuset.mems", res.CpuSetMems); err != nil {
+				return fmt.Errorf("set cgroup cpuset mems fail %v", err)
+			}
+		}
 		return nil
 	} else {
 		return err
@@ -25,7 +43,7 @@ func (s *CpusetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 
 func (s *CpusetSubSystem) Remove(cgroupPath string) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		return os.RemoveAll(subsysCgroupPath)
+		return FS.RemoveAll(subsysCgroupPath)
 	} else {
 		return err
 	}
@@ -33,7 +51,7 @@ func (s *CpusetSubSystem) Remove(cgroupPath string) error {
 
 func (s *CpusetSubSystem) Apply(cgroupPath string, pid int) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		if err := os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -45,3 +63,116 @@ func (s *CpusetSubSystem) Apply(cgroupPath string, pid int) error {
 func (s *CpusetSubSystem) Name() string {
 	return "cpuset"
 }
+
+// copy the cpuset.cpus and cpuset.mems from the parent to every empty level of the cgroupPath
+func inheritCpuset(cgroupRoot, cgroupPath string) error {
+	parent := cgroupRoot
+	for _, elem := range strings.Split(strings.Trim(path.Clean(cgroupPath), "/"), "/") {
+		current := path.Join(parent, elem)
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			value, err := readCgroupFile(current, file)
+			if err != nil {
+				return fmt.Errorf("read %s of %s fail %v", file, current, err)
+			}
+			if value != "" {
+				continue
+			}
+			if value, err = readCgroupFile(parent, file); err != nil {
+				return fmt.Errorf("read %s of %s fail %v", file, parent, err)
+			}
+			if err := writeCgroupFile(current, file, value); err != nil {
+				return fmt.Errorf("inherit %s of %s fail %v", file, current, err)
+			}
+		}
+		parent = current
+	}
+	return nil
+}
+
+// check every cpu or memory node of the list is offered by the parent cpuset,
+// the effective file is not provided by the old kernel so the fallback is read
+func checkCpusetAvailable(list, parentPath, effectiveFile, fallbackFile, kind string) error {
+	requested, err := ParseCPUList(list)
+	if err != nil {
+		return err
+	}
+	available, err := readCgroupFile(parentPath, effectiveFile)
+	if os.IsNotExist(err) && fallbackFile != "" {
+		available, err = readCgroupFile(parentPath, fallbackFile)
+	}
+	if err != nil {
+		return fmt.Errorf("read available %s of %s fail %v", kind, parentPath, err)
+	}
+	offered, err := ParseCPUList(available)
+	if err != nil {
+		return err
+	}
+	offeredSet := make(map[int]bool)
+	for _, id := range offered {
+		offeredSet[id] = true
+	}
+	for _, id := range requested {
+		if !offeredSet[id] {
+			return fmt.Errorf("%s %d is not available,the parent cpuset only offer %s", kind, id, available)
+		}
+	}
+	return nil
+}
+
+// the kernel is built with at most 8192 cpus,an id above it can't be valid
+// and the range is checked before expanding so a typo never allocate a huge set
+const maxCPUListID = 8191
+
+// ParseCPUList parse the list like "0-3,6" used by the cpuset into the sorted ids
+func ParseCPUList(list string) ([]int, error) {
+	set := make(map[int]bool)
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu list %s", list)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %s", list)
+			}
+		}
+		if end > maxCPUListID {
+			return nil, fmt.Errorf("invalid cpu list %s,the id %d is above the max %d", list, end, maxCPUListID)
+		}
+		for id := start; id <= end; id++ {
+			set[id] = true
+		}
+	}
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// FormatCPUList format the ids into the list like "0-3,6"
+func FormatCPUList(ids []int) string {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,94 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list string
+		want []int
+	}{
+		{"", nil},
+		{" \n", nil},
+		{"0", []int{0}},
+		{"0-3", []int{0, 1, 2, 3}},
+		{"0-1,4,6-7\n", []int{0, 1, 4, 6, 7}},
+		{"3,1-2,2", []int{1, 2, 3}},
+		{"5-5", []int{5}},
+		{"8190-8191", []int{8190, 8191}},
+	}
+	for _, test := range tests {
+		got, err := ParseCPUList(test.list)
+		if err != nil {
+			t.Errorf("%q: %v", test.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q = %v,want %v", test.list, got, test.want)
+		}
+	}
+	for _, list := range []string{"a", "-1", "0-", "3-1", "0,,1", "0-1-2", "1 2",
+		"0-2000000000", "8192", "0-8192", "99999999999999999999"} {
+		if _, err := ParseCPUList(list); err == nil {
+			t.Errorf("%q: want error", list)
+		}
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	tests := []struct {
+		ids  []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{0}, "0"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{0, 1, 4, 6, 7}, "0-1,4,6-7"},
+		{[]int{7, 6, 0}, "0,6-7"},
+	}
+	for _, test := range tests {
+		if got := FormatCPUList(test.ids); got != test.want {
+			t.Errorf("%v = %q,want %q", test.ids, got, test.want)
+		}
+		ids, err := ParseCPUList(test.want)
+		if err != nil || FormatCPUList(ids) != test.want {
+			t.Errorf("%q does not round trip: %v %v", test.want, ids, err)
+		}
+	}
+}
+
+func TestCheckCpusetAvailable(t *testing.T) {
+	oldFS := FS
+	t.Cleanup(func() { FS = oldFS })
+	fs := fakefs.New(nil)
+	FS = fs
+	if err := fs.MkdirAll("/parent", 0755); err != nil {
+		t.Fatal(err)
+	}
+	fs.WriteFile("/parent/cpuset.effective_cpus", []byte("0-3\n"), 0644)
+	fs.WriteFile("/parent/cpuset.mems", []byte("0\n"), 0644)
+
+	tests := []struct {
+		list, effectiveFile, fallbackFile string
+		ok                                bool
+	}{
+		{"0-3", "cpuset.effective_cpus", "cpuset.cpus", true},
+		{"1,3", "cpuset.effective_cpus", "cpuset.cpus", true},
+		{"", "cpuset.effective_cpus", "cpuset.cpus", true},
+		{"2-4", "cpuset.effective_cpus", "cpuset.cpus", false},
+		{"x", "cpuset.effective_cpus", "cpuset.cpus", false},
+		//the kernel without the effective file fall back to the configured one
+		{"0", "cpuset.effective_mems", "cpuset.mems", true},
+		{"1", "cpuset.effective_mems", "cpuset.mems", false},
+		{"0", "cpuset.effective_mems", "", false},
+	}
+	for _, test := range tests {
+		err := checkCpusetAvailable(test.list, "/parent", test.effectiveFile, test.fallbackFile, "cpu")
+		if (err == nil) != test.ok {
+			t.Errorf("%q in %s: error %v,want ok %v", test.list, test.effectiveFile, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package subsystem
+
+import (
+	"fmt"
+	"path"
+)
+
+// CpusetSubSystemV2 is the cpuset controller of the unified hierarchy
+type CpusetSubSystemV2 struct {
+}
+
+// an empty cpuset.cpus or cpuset.mems of v2 use the effective one of the parent,
+// so nothing need to be inherited as v1
+func (s *CpusetSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	parentPath := path.Dir(subsysCgroupPath)
+	if res.CpuSet != "" {
+		if err := checkCpusetAvailable(res.CpuSet, parentPath, "cpuset.cpus.effective", "", "cpu"); err != nil {
+			return err
+		}
+		if err := writeCgroupFile(subsysCgroupPath, "cpuset.cpus", res.CpuSet); err != nil {
+			return fmt.Errorf("set cgroup cpuset fail %v", err)
+		}
+	}
+	if res.CpuSetMems != "" {
+		if err := checkCpusetAvailable(res.CpuSetMems, parentPath, "cpuset.mems.effective", "", "memory node"); err != nil {
+			return err
+		}
+		if err := writeCgroupFile(subsysCgroupPath, "cpuset.mems", res.CpuSetMems); err != nil {
+			return fmt.Errorf("set cgroup cpuset mems fail %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *CpusetSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *CpusetSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *CpusetSubSystemV2) Name() string {
+	return "cpuset"
+}
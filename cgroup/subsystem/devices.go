@@ -0,0 +1,244 @@
+package subsystem
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DeviceRule is one rule of the device access,like "c 1:3 rwm"
+type DeviceRule struct {
+	// a for all,b for block,c for char
+	Type string `json:"type"`
+	// -1 means any number
+	Major       int64  `json:"major"`
+	Minor       int64  `json:"minor"`
+	Permissions string `json:"permissions"`
+}
+
+func (r *DeviceRule) String() string {
+	if r.Type == "a" {
+		return "a"
+	}
+	number := func(n int64) string {
+		if n == -1 {
+			return "*"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf("%s %s:%s %s", r.Type, number(r.Major), number(r.Minor), r.Permissions)
+}
+
+// Device is a host device added to the container by --device
+type Device struct {
+	DeviceRule
+	// the path of the device on the host and in the container
+	HostPath string      `json:"hostPath"`
+	Path     string      `json:"path"`
+	FileMode os.FileMode `json:"fileMode"`
+	Uid      uint32      `json:"uid"`
+	Gid      uint32      `json:"gid"`
+}
+
+// DefaultAllowedDevices is the devices every container can access
+var DefaultAllowedDevices = []*DeviceRule{
+	// /dev/null
+	{Type: "c", Major: 1, Minor: 3, Permissions: "rwm"},
+	// /dev/zero
+	{Type: "c", Major: 1, Minor: 5, Permissions: "rwm"},
+	// /dev/full
+	{Type: "c", Major: 1, Minor: 7, Permissions: "rwm"},
+	// /dev/random
+	{Type: "c", Major: 1, Minor: 8, Permissions: "rwm"},
+	// /dev/urandom
+	{Type: "c", Major: 1, Minor: 9, Permissions: "rwm"},
+	// /dev/tty
+	{Type: "c", Major: 5, Minor: 0, Permissions: "rwm"},
+	// /dev/ptmx,the pts can not be allocated without it
+	{Type: "c", Major: 5, Minor: 2, Permissions: "rwm"},
+	// /dev/pts/*
+	{Type: "c", Major: 136, Minor: -1, Permissions: "rwm"},
+}
+
+// the default devices and the devices added by --device
+func allowedDeviceRules(res *ResourceConfig) []*DeviceRule {
+	rules := append([]*DeviceRule(nil), DefaultAllowedDevices...)
+	for _, device := range res.Devices {
+		rule := device.DeviceRule
+		rules = append(rules, &rule)
+	}
+	return rules
+}
+
+type DevicesSubSystem struct {
+}
+
+// the new cgroup inherit "a *:* rwm" and get all the devices denied first,after that only
+// the difference from devices.list is written,so update never deny the devices in use for a while
+func (s *DevicesSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	content, err := FS.ReadFile(path.Join(subsysCgroupPath, "devices.list"))
+	if err != nil {
+		return fmt.Errorf("read cgroup devices list fail %v", err)
+	}
+	current := parseDevicesList(string(content))
+	if _, ok := current["a *:*"]; ok {
+		if err := writeCgroupFile(subsysCgroupPath, "devices.deny", "a"); err != nil {
+			return fmt.Errorf("set cgroup devices deny fail %v", err)
+		}
+		current = map[string]string{}
+	}
+	wanted := map[string]string{}
+	var keys []string
+	for _, rule := range allowedDeviceRules(res) {
+		key := deviceKey(rule)
+		if _, ok := wanted[key]; !ok {
+			keys = append(keys, key)
+		}
+		wanted[key] = mergePermissions(wanted[key], rule.Permissions)
+	}
+	// allow the new ones first,the kernel merge the permissions of the same device
+	for _, key := range keys {
+		if missing := subtractPermissions(wanted[key], current[key]); missing != "" {
+			if err := writeCgroupFile(subsysCgroupPath, "devices.allow", key+" "+missing); err != nil {
+				return fmt.Errorf("set cgroup devices allow %s %s fail %v", key, missing, err)
+			}
+		}
+	}
+	var stale []string
+	for key := range current {
+		stale = append(stale, key)
+	}
+	sort.Strings(stale)
+	for _, key := range stale {
+		if extra := subtractPermissions(current[key], wanted[key]); extra != "" {
+			if err := writeCgroupFile(subsysCgroupPath, "devices.deny", key+" "+extra); err != nil {
+				return fmt.Errorf("set cgroup devices deny %s %s fail %v", key, extra, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseDevicesList read the "c 1:3 rwm" lines into the permissions by "c 1:3"
+func parseDevicesList(content string) map[string]string {
+	list := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		key := fields[0] + " " + fields[1]
+		list[key] = mergePermissions(list[key], fields[2])
+	}
+	return list
+}
+
+// deviceKey is the rule without the permissions in the format of devices.list
+func deviceKey(rule *DeviceRule) string {
+	if rule.Type == "a" {
+		return "a *:*"
+	}
+	return strings.TrimSuffix(rule.String(), " "+rule.Permissions)
+}
+
+// the permissions are always kept in the order of rwm
+func mergePermissions(a, b string) string {
+	var merged string
+	for _, p := range "rwm" {
+		if strings.ContainsRune(a, p) || strings.ContainsRune(b, p) {
+			merged += string(p)
+		}
+	}
+	return merged
+}
+
+func subtractPermissions(a, b string) string {
+	var left string
+	for _, p := range "rwm" {
+		if strings.ContainsRune(a, p) && !strings.ContainsRune(b, p) {
+			left += string(p)
+		}
+	}
+	return left
+}
+
+func (s *DevicesSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *DevicesSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+func (s *DevicesSubSystem) Name() string {
+	return "devices"
+}
+
+// ParseDevice parse the "/dev/fuse[:/dev/fuse][:rwm]" into the device added to the container
+func ParseDevice(spec string) (*Device, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) == 0 || len(parts) > 3 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid device %s,the format is <host-path>[:<container-path>][:<permissions>]", spec)
+	}
+	device := &Device{HostPath: parts[0], Path: parts[0]}
+	device.Permissions = "rwm"
+	switch len(parts) {
+	case 2:
+		if isDevicePermissions(parts[1]) {
+			device.Permissions = parts[1]
+		} else {
+			device.Path = parts[1]
+		}
+	case 3:
+		if !isDevicePermissions(parts[2]) {
+			return nil, fmt.Errorf("invalid device permissions %s,only r,w and m are allowed", parts[2])
+		}
+		device.Path = parts[1]
+		device.Permissions = parts[2]
+	}
+	if !path.IsAbs(device.Path) {
+		return nil, fmt.Errorf("the device path %s in the container should be absolute", device.Path)
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(device.HostPath, &stat); err != nil {
+		return nil, fmt.Errorf("stat device %s error %v", device.HostPath, err)
+	}
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFCHR:
+		device.Type = "c"
+	case syscall.S_IFBLK:
+		device.Type = "b"
+	default:
+		return nil, fmt.Errorf("%s is not a device", device.HostPath)
+	}
+	device.Major = int64(unix.Major(uint64(stat.Rdev)))
+	device.Minor = int64(unix.Minor(uint64(stat.Rdev)))
+	device.FileMode = os.FileMode(stat.Mode & 0777)
+	device.Uid = stat.Uid
+	device.Gid = stat.Gid
+	return device, nil
+}
+
+func isDevicePermissions(s string) bool {
+	return s != "" && strings.Trim(s, "rwm") == ""
+}
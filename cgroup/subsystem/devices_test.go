@@ -0,0 +1,107 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// recordingFS keep every write in order,the fake fs only keep the last content of a file
+type recordingFS struct {
+	*fakefs.FileSystem
+	writes []string
+}
+
+func (r *recordingFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	r.writes = append(r.writes, path.Base(name)+" "+string(data))
+	return r.FileSystem.WriteFile(name, data, perm)
+}
+
+func useFakeDevicesCgroup(t *testing.T, list string) *recordingFS {
+	fs := &recordingFS{FileSystem: fakefs.New(map[string]string{"devices.list": list, "tasks": ""})}
+	if err := fs.MkdirAll("/sys/fs/cgroup/devices", 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldFS, oldMountInfo := FS, OpenMountInfo
+	FS = fs
+	OpenMountInfo = fakefs.MountInfo("38 25 0:34 / /sys/fs/cgroup/devices rw,nosuid,nodev,noexec,relatime shared:17 - cgroup cgroup rw,devices\n")
+	t.Cleanup(func() {
+		FS, OpenMountInfo = oldFS, oldMountInfo
+	})
+	return fs
+}
+
+func fuseDevice(permissions string) *Device {
+	return &Device{DeviceRule: DeviceRule{Type: "c", Major: 10, Minor: 229, Permissions: permissions}}
+}
+
+func TestDevicesSetNewCgroup(t *testing.T) {
+	fs := useFakeDevicesCgroup(t, "a *:* rwm\n")
+	if err := (&DevicesSubSystem{}).Set("mydocker/abc", &ResourceConfig{Devices: []*Device{fuseDevice("rwm")}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"devices.deny a"}
+	for _, rule := range DefaultAllowedDevices {
+		want = append(want, "devices.allow "+rule.String())
+	}
+	want = append(want, "devices.allow c 10:229 rwm")
+	if !reflect.DeepEqual(fs.writes, want) {
+		t.Errorf("writes %q,want %q", fs.writes, want)
+	}
+}
+
+func TestDevicesSetUpdate(t *testing.T) {
+	var defaults string
+	for _, rule := range DefaultAllowedDevices {
+		defaults += rule.String() + "\n"
+	}
+	tests := []struct {
+		name    string
+		list    string
+		devices []*Device
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			list:    defaults + "c 10:229 rwm\n",
+			devices: []*Device{fuseDevice("rwm")},
+		},
+		{
+			name:    "add",
+			list:    defaults,
+			devices: []*Device{fuseDevice("mr")},
+			want:    []string{"devices.allow c 10:229 rm"},
+		},
+		{
+			name:    "remove",
+			list:    defaults + "c 10:229 rwm\nb 8:0 r\n",
+			devices: nil,
+			want:    []string{"devices.deny b 8:0 r", "devices.deny c 10:229 rwm"},
+		},
+		{
+			name:    "narrow permissions",
+			list:    defaults + "c 10:229 rwm\n",
+			devices: []*Device{fuseDevice("r")},
+			want:    []string{"devices.deny c 10:229 wm"},
+		},
+		{
+			name:    "replace",
+			list:    defaults + "b 8:0 rwm\n",
+			devices: []*Device{fuseDevice("rw")},
+			want:    []string{"devices.allow c 10:229 rw", "devices.deny b 8:0 rwm"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := useFakeDevicesCgroup(t, test.list)
+			if err := (&DevicesSubSystem{}).Set("mydocker/abc", &ResourceConfig{Devices: test.devices}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fs.writes, test.want) {
+				t.Errorf("writes %q,want %q", fs.writes, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package subsystem
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// DeviceFilterAttacher put the allowed device rules into effect on a cgroup of the unified hierarchy
+type DeviceFilterAttacher interface {
+	Attach(cgroupDir string, rules []*DeviceRule) error
+}
+
+// DeviceFilter is the attacher used by the DevicesSubSystemV2,it is replaced
+// together with the FS since a fake cgroup dir can not hold a BPF program
+var DeviceFilter DeviceFilterAttacher = bpfDeviceFilter{}
+
+// bpfDeviceFilter compile the rules into a BPF program and attach it to the cgroup dir
+type bpfDeviceFilter struct{}
+
+func (bpfDeviceFilter) Attach(cgroupDir string, rules []*DeviceRule) error {
+	insns, err := deviceFilterProgram(rules)
+	if err != nil {
+		return err
+	}
+	dir, err := FS.Open(cgroupDir)
+	if err != nil {
+		return fmt.Errorf("open cgroup %s fail %v", cgroupDir, err)
+	}
+	defer dir.Close()
+	if err := attachDeviceFilter(int(dir.Fd()), insns); err != nil {
+		return fmt.Errorf("attach device filter to %s fail %v", cgroupDir, err)
+	}
+	return nil
+}
+
+// DevicesSubSystemV2 attach a BPF_PROG_TYPE_CGROUP_DEVICE program to the cgroup,
+// the unified hierarchy has no devices controller files
+type DevicesSubSystemV2 struct {
+}
+
+func (s *DevicesSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path("", cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	return DeviceFilter.Attach(subsysCgroupPath, allowedDeviceRules(res))
+}
+
+func (s *DevicesSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *DevicesSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *DevicesSubSystemV2) Name() string {
+	return "devices"
+}
+
+// bpfInsn is the struct bpf_insn,the dst register is the low 4 bits of regs
+type bpfInsn struct {
+	Code uint8
+	Regs uint8
+	Off  int16
+	Imm  int32
+}
+
+const (
+	bpfLdxMemW  = 0x61 // BPF_LDX | BPF_MEM | BPF_W
+	bpfAnd32K   = 0x54 // BPF_ALU | BPF_AND | BPF_K
+	bpfRsh32K   = 0x74 // BPF_ALU | BPF_RSH | BPF_K
+	bpfMov32X   = 0xbc // BPF_ALU | BPF_MOV | BPF_X
+	bpfMov64K   = 0xb7 // BPF_ALU64 | BPF_MOV | BPF_K
+	bpfJneK     = 0x55 // BPF_JMP | BPF_JNE | BPF_K
+	bpfExitInsn = 0x95 // BPF_JMP | BPF_EXIT
+)
+
+func insn(code, dst, src uint8, off int16, imm int32) bpfInsn {
+	return bpfInsn{Code: code, Regs: dst | src<<4, Off: off, Imm: imm}
+}
+
+// deviceFilterProgram generate the program checking the struct bpf_cgroup_dev_ctx
+// {u32 access_type; u32 major; u32 minor} against the allowed rules,
+// it return 1 for the first matched rule and 0 when no rule match
+func deviceFilterProgram(rules []*DeviceRule) ([]bpfInsn, error) {
+	insns := []bpfInsn{
+		// r2 = type,r3 = access,r4 = major,r5 = minor
+		insn(bpfLdxMemW, 2, 1, 0, 0),
+		insn(bpfAnd32K, 2, 0, 0, 0xffff),
+		insn(bpfLdxMemW, 3, 1, 0, 0),
+		insn(bpfRsh32K, 3, 0, 0, 16),
+		insn(bpfLdxMemW, 4, 1, 4, 0),
+		insn(bpfLdxMemW, 5, 1, 8, 0),
+	}
+	for _, rule := range rules {
+		block, err := deviceRuleBlock(rule)
+		if err != nil {
+			return nil, err
+		}
+		insns = append(insns, block...)
+	}
+	insns = append(insns,
+		insn(bpfMov64K, 0, 0, 0, 0),
+		insn(bpfExitInsn, 0, 0, 0, 0),
+	)
+	return insns, nil
+}
+
+// every mismatch jump over the rest of the block to the next rule
+func deviceRuleBlock(rule *DeviceRule) ([]bpfInsn, error) {
+	var block []bpfInsn
+	switch rule.Type {
+	case "a":
+	case "b":
+		block = append(block, insn(bpfJneK, 2, 0, 0, unix.BPF_DEVCG_DEV_BLOCK))
+	case "c":
+		block = append(block, insn(bpfJneK, 2, 0, 0, unix.BPF_DEVCG_DEV_CHAR))
+	default:
+		return nil, fmt.Errorf("invalid device type %s", rule.Type)
+	}
+	var access int32
+	for _, p := range rule.Permissions {
+		switch p {
+		case 'r':
+			access |= unix.BPF_DEVCG_ACC_READ
+		case 'w':
+			access |= unix.BPF_DEVCG_ACC_WRITE
+		case 'm':
+			access |= unix.BPF_DEVCG_ACC_MKNOD
+		default:
+			return nil, fmt.Errorf("invalid device permissions %s", rule.Permissions)
+		}
+	}
+	allAccess := int32(unix.BPF_DEVCG_ACC_READ | unix.BPF_DEVCG_ACC_WRITE | unix.BPF_DEVCG_ACC_MKNOD)
+	if access != allAccess {
+		// the requested access must not have any bit out of the rule
+		block = append(block,
+			insn(bpfMov32X, 1, 3, 0, 0),
+			insn(bpfAnd32K, 1, 0, 0, ^access&allAccess),
+			insn(bpfJneK, 1, 0, 0, 0),
+		)
+	}
+	if rule.Type != "a" && rule.Major != -1 {
+		block = append(block, insn(bpfJneK, 4, 0, 0, int32(rule.Major)))
+	}
+	if rule.Type != "a" && rule.Minor != -1 {
+		block = append(block, insn(bpfJneK, 5, 0, 0, int32(rule.Minor)))
+	}
+	block = append(block,
+		insn(bpfMov64K, 0, 0, 0, 1),
+		insn(bpfExitInsn, 0, 0, 0, 0),
+	)
+	for i := range block {
+		if block[i].Code == bpfJneK {
+			block[i].Off = int16(len(block) - i - 1)
+		}
+	}
+	return block, nil
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	r, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return r, nil
+}
+
+// load the program and attach it to the cgroup,the programs attached before
+// are detached after the new one take effect so update never leave the cgroup unfiltered
+func attachDeviceFilter(cgroupFd int, insns []bpfInsn) error {
+	license := []byte("Apache\x00")
+	loadAttr := struct {
+		ProgType uint32
+		InsnCnt  uint32
+		Insns    uint64
+		License  uint64
+		LogLevel uint32
+		LogSize  uint32
+		LogBuf   uint64
+	}{
+		ProgType: unix.BPF_PROG_TYPE_CGROUP_DEVICE,
+		InsnCnt:  uint32(len(insns)),
+		Insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		License:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+	progFd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&loadAttr), unsafe.Sizeof(loadAttr))
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+	if err != nil {
+		return fmt.Errorf("load device program fail %v", err)
+	}
+	defer unix.Close(int(progFd))
+
+	oldProgIds, err := queryDeviceFilters(cgroupFd)
+	if err != nil {
+		return err
+	}
+	attachAttr := struct {
+		TargetFd    uint32
+		AttachBpfFd uint32
+		AttachType  uint32
+		AttachFlags uint32
+	}{
+		TargetFd:    uint32(cgroupFd),
+		AttachBpfFd: uint32(progFd),
+		AttachType:  unix.BPF_CGROUP_DEVICE,
+		AttachFlags: unix.BPF_F_ALLOW_MULTI,
+	}
+	if _, err := bpf(unix.BPF_PROG_ATTACH, unsafe.Pointer(&attachAttr), unsafe.Sizeof(attachAttr)); err != nil {
+		return fmt.Errorf("attach device program fail %v", err)
+	}
+	var errs []string
+	for _, id := range oldProgIds {
+		if err := detachDeviceFilter(cgroupFd, id); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("detach old device programs fail %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// return the ids of the device programs attached to the cgroup
+func queryDeviceFilters(cgroupFd int) ([]uint32, error) {
+	ids := make([]uint32, 64)
+	queryAttr := struct {
+		TargetFd    uint32
+		AttachType  uint32
+		QueryFlags  uint32
+		AttachFlags uint32
+		ProgIds     uint64
+		ProgCnt     uint32
+	}{
+		TargetFd:   uint32(cgroupFd),
+		AttachType: unix.BPF_CGROUP_DEVICE,
+		ProgIds:    uint64(uintptr(unsafe.Pointer(&ids[0]))),
+		ProgCnt:    uint32(len(ids)),
+	}
+	_, err := bpf(unix.BPF_PROG_QUERY, unsafe.Pointer(&queryAttr), unsafe.Sizeof(queryAttr))
+	runtime.KeepAlive(ids)
+	if err != nil {
+		return nil, fmt.Errorf("query device programs fail %v", err)
+	}
+	return ids[:queryAttr.ProgCnt], nil
+}
+
+func detachDeviceFilter(cgroupFd int, progId uint32) error {
+	getFdAttr := struct {
+		ProgId    uint32
+		NextId    uint32
+		OpenFlags uint32
+	}{ProgId: progId}
+	progFd, err := bpf(unix.BPF_PROG_GET_FD_BY_ID, unsafe.Pointer(&getFdAttr), unsafe.Sizeof(getFdAttr))
+	if err != nil {
+		return fmt.Errorf("get device program %d fail %v", progId, err)
+	}
+	defer unix.Close(int(progFd))
+	detachAttr := struct {
+		TargetFd    uint32
+		AttachBpfFd uint32
+		AttachType  uint32
+	}{
+		TargetFd:    uint32(cgroupFd),
+		AttachBpfFd: uint32(progFd),
+		AttachType:  unix.BPF_CGROUP_DEVICE,
+	}
+	if _, err := bpf(unix.BPF_PROG_DETACH, unsafe.Pointer(&detachAttr), unsafe.Sizeof(detachAttr)); err != nil {
+		return fmt.Errorf("detach device program %d fail %v", progId, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package subsystem
+
+import (
+	"golang.org/x/sys/unix"
+	"reflect"
+	"testing"
+)
+
+// runDeviceFilter interpret the few instructions the generator use against the bpf_cgroup_dev_ctx
+func runDeviceFilter(t *testing.T, insns []bpfInsn, devType, access, major, minor uint32) uint64 {
+	t.Helper()
+	ctx := []uint32{access<<16 | devType, major, minor}
+	var regs [11]uint64
+	for pc := 0; pc < len(insns); pc++ {
+		in := insns[pc]
+		dst, src := in.Regs&0xf, in.Regs>>4
+		switch in.Code {
+		case bpfLdxMemW:
+			if src != 1 {
+				t.Fatalf("load from r%d at %d", src, pc)
+			}
+			regs[dst] = uint64(ctx[in.Off/4])
+		case bpfAnd32K:
+			regs[dst] = uint64(uint32(regs[dst]) & uint32(in.Imm))
+		case bpfRsh32K:
+			regs[dst] = uint64(uint32(regs[dst]) >> uint32(in.Imm))
+		case bpfMov32X:
+			regs[dst] = uint64(uint32(regs[src]))
+		case bpfMov64K:
+			regs[dst] = uint64(int64(in.Imm))
+		case bpfJneK:
+			if regs[dst] != uint64(int64(in.Imm)) {
+				pc += int(in.Off)
+			}
+		case bpfExitInsn:
+			return regs[0]
+		default:
+			t.Fatalf("unknown instruction %#x at %d", in.Code, pc)
+		}
+	}
+	t.Fatal("the program does not exit")
+	return 0
+}
+
+func TestDeviceRuleBlock(t *testing.T) {
+	allow := []bpfInsn{insn(bpfMov64K, 0, 0, 0, 1), insn(bpfExitInsn, 0, 0, 0, 0)}
+	tests := []struct {
+		rule DeviceRule
+		want []bpfInsn
+	}{
+		{
+			rule: DeviceRule{Type: "a", Major: -1, Minor: -1, Permissions: "rwm"},
+			want: allow,
+		},
+		{
+			rule: DeviceRule{Type: "c", Major: 1, Minor: 3, Permissions: "rwm"},
+			want: append([]bpfInsn{
+				insn(bpfJneK, 2, 0, 4, unix.BPF_DEVCG_DEV_CHAR),
+				insn(bpfJneK, 4, 0, 3, 1),
+				insn(bpfJneK, 5, 0, 2, 3),
+			}, allow...),
+		},
+		{
+			rule: DeviceRule{Type: "c", Major: 136, Minor: -1, Permissions: "rwm"},
+			want: append([]bpfInsn{
+				insn(bpfJneK, 2, 0, 3, unix.BPF_DEVCG_DEV_CHAR),
+				insn(bpfJneK, 4, 0, 2, 136),
+			}, allow...),
+		},
+		{
+			rule: DeviceRule{Type: "b", Major: -1, Minor: -1, Permissions: "rwm"},
+			want: append([]bpfInsn{
+				insn(bpfJneK, 2, 0, 2, unix.BPF_DEVCG_DEV_BLOCK),
+			}, allow...),
+		},
+		{
+			rule: DeviceRule{Type: "c", Major: 10, Minor: 229, Permissions: "r"},
+			want: append([]bpfInsn{
+				insn(bpfJneK, 2, 0, 7, unix.BPF_DEVCG_DEV_CHAR),
+				insn(bpfMov32X, 1, 3, 0, 0),
+				insn(bpfAnd32K, 1, 0, 0, unix.BPF_DEVCG_ACC_WRITE|unix.BPF_DEVCG_ACC_MKNOD),
+				insn(bpfJneK, 1, 0, 4, 0),
+				insn(bpfJneK, 4, 0, 3, 10),
+				insn(bpfJneK, 5, 0, 2, 229),
+			}, allow...),
+		},
+	}
+	for _, test := range tests {
+		block, err := deviceRuleBlock(&test.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", test.rule.String(), err)
+		}
+		if !reflect.DeepEqual(block, test.want) {
+			t.Errorf("%s: block %+v,want %+v", test.rule.String(), block, test.want)
+		}
+	}
+
+	for _, rule := range []DeviceRule{
+		{Type: "x", Major: 1, Minor: 3, Permissions: "rwm"},
+		{Type: "c", Major: 1, Minor: 3, Permissions: "rx"},
+	} {
+		if _, err := deviceRuleBlock(&rule); err == nil {
+			t.Errorf("%+v: want error", rule)
+		}
+	}
+}
+
+func TestDeviceFilterProgram(t *testing.T) {
+	const (
+		r, w, m   = unix.BPF_DEVCG_ACC_READ, unix.BPF_DEVCG_ACC_WRITE, unix.BPF_DEVCG_ACC_MKNOD
+		char, blk = unix.BPF_DEVCG_DEV_CHAR, unix.BPF_DEVCG_DEV_BLOCK
+	)
+	rules := append([]*DeviceRule(nil), DefaultAllowedDevices...)
+	rules = append(rules,
+		&DeviceRule{Type: "c", Major: 10, Minor: 229, Permissions: "r"},
+		&DeviceRule{Type: "b", Major: -1, Minor: -1, Permissions: "m"},
+	)
+	insns, err := deviceFilterProgram(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := insns[len(insns)-2:]
+	if !reflect.DeepEqual(last, []bpfInsn{insn(bpfMov64K, 0, 0, 0, 0), insn(bpfExitInsn, 0, 0, 0, 0)}) {
+		t.Errorf("the program should end with deny,got %+v", last)
+	}
+	tests := []struct {
+		name                       string
+		devType, access, maj, mino uint32
+		want                       uint64
+	}{
+		{"null", char, r | w | m, 1, 3, 1},
+		{"not allowed minor", char, r, 1, 4, 0},
+		{"pts any minor", char, w, 136, 7, 1},
+		{"pts other major", char, w, 137, 7, 0},
+		{"block null", blk, r, 1, 3, 0},
+		{"fuse read", char, r, 10, 229, 1},
+		{"fuse write", char, w, 10, 229, 0},
+		{"fuse read write", char, r | w, 10, 229, 0},
+		{"block mknod", blk, m, 8, 0, 1},
+		{"block read", blk, r, 8, 0, 0},
+	}
+	for _, test := range tests {
+		if got := runDeviceFilter(t, insns, test.devType, test.access, test.maj, test.mino); got != test.want {
+			t.Errorf("%s: got %d,want %d", test.name, got, test.want)
+		}
+	}
+
+	allowAll, err := deviceFilterProgram([]*DeviceRule{{Type: "a", Major: -1, Minor: -1, Permissions: "rwm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runDeviceFilter(t, allowAll, blk, r|w, 8, 0); got != 1 {
+		t.Errorf("a *:* rwm: got %d,want 1", got)
+	}
+	denyAll, err := deviceFilterProgram(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runDeviceFilter(t, denyAll, char, r, 1, 3); got != 0 {
+		t.Errorf("no rules: got %d,want 0", got)
+	}
+}
@@ -0,0 +1,230 @@
+// Package fakefs is an in-memory cgroup file system for the tests,it is set as the
+// subsystem.FS together with a MountInfo so the cgroup layer run without root
+package fakefs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileSystem keep the files in memory and behave like the cgroup file system:
+// a new directory is populated with the Defaults files and a directory can only
+// be removed when it has no child directory
+type FileSystem struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	// Defaults is the files and their content every new directory get
+	Defaults map[string]string
+}
+
+// New create a fake file system only have the "/" directory
+func New(defaults map[string]string) *FileSystem {
+	return &FileSystem{
+		files:    make(map[string][]byte),
+		dirs:     map[string]bool{"/": true},
+		Defaults: defaults,
+	}
+}
+
+// MountInfo return a mountinfo opener which can be used as the subsystem.OpenMountInfo
+func MountInfo(mountinfo string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(mountinfo)), nil
+	}
+}
+
+func fakePathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *FileSystem) ReadFile(name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	data, ok := f.files[name]
+	if !ok {
+		if f.dirs[name] {
+			return nil, fakePathError("read", name, syscall.EISDIR)
+		}
+		return nil, fakePathError("open", name, os.ErrNotExist)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Open can not give the real fd the kernel interfaces need,such as the oom eventfd
+func (f *FileSystem) Open(name string) (*os.File, error) {
+	return nil, fakePathError("open", path.Clean(name), syscall.EOPNOTSUPP)
+}
+
+func (f *FileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	if !f.dirs[path.Dir(name)] {
+		return fakePathError("open", name, os.ErrNotExist)
+	}
+	if f.dirs[name] {
+		return fakePathError("open", name, syscall.EISDIR)
+	}
+	if path.Base(name) == "cgroup.subtree_control" {
+		data = []byte(mergeSubtreeControl(string(f.files[name]), string(data)))
+	}
+	f.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// the "+controller" and "-controller" written to the cgroup.subtree_control
+// change the enabled controllers instead of replacing them
+func mergeSubtreeControl(enabled, change string) string {
+	controllers := strings.Fields(enabled)
+	for _, field := range strings.Fields(change) {
+		name := strings.TrimLeft(field, "+-")
+		kept := controllers[:0]
+		for _, controller := range controllers {
+			if controller != name {
+				kept = append(kept, controller)
+			}
+		}
+		controllers = kept
+		if !strings.HasPrefix(field, "-") {
+			controllers = append(controllers, name)
+		}
+	}
+	return strings.Join(controllers, " ")
+}
+
+func (f *FileSystem) MkdirAll(dir string, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir = path.Clean(dir)
+	if _, ok := f.files[dir]; ok {
+		return fakePathError("mkdir", dir, syscall.ENOTDIR)
+	}
+	if f.dirs[dir] {
+		return nil
+	}
+	var missing []string
+	for d := dir; !f.dirs[d]; d = path.Dir(d) {
+		if _, ok := f.files[d]; ok {
+			return fakePathError("mkdir", d, syscall.ENOTDIR)
+		}
+		missing = append(missing, d)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		f.dirs[missing[i]] = true
+		for file, content := range f.Defaults {
+			f.files[path.Join(missing[i], file)] = []byte(content)
+		}
+	}
+	return nil
+}
+
+func (f *FileSystem) Remove(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	if _, ok := f.files[name]; ok {
+		delete(f.files, name)
+		return nil
+	}
+	if !f.dirs[name] {
+		return fakePathError("remove", name, os.ErrNotExist)
+	}
+	for d := range f.dirs {
+		if path.Dir(d) == name && d != name {
+			return fakePathError("remove", name, syscall.EBUSY)
+		}
+	}
+	//the control files go away with the cgroup
+	for file := range f.files {
+		if path.Dir(file) == name {
+			delete(f.files, file)
+		}
+	}
+	delete(f.dirs, name)
+	return nil
+}
+
+func (f *FileSystem) RemoveAll(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for file := range f.files {
+		if file == name || strings.HasPrefix(file, prefix) {
+			delete(f.files, file)
+		}
+	}
+	for d := range f.dirs {
+		if d != "/" && (d == name || strings.HasPrefix(d, prefix)) {
+			delete(f.dirs, d)
+		}
+	}
+	return nil
+}
+
+func (f *FileSystem) Stat(name string) (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	if f.dirs[name] {
+		return &fakeFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	if data, ok := f.files[name]; ok {
+		return &fakeFileInfo{name: path.Base(name), size: int64(len(data))}, nil
+	}
+	return nil, fakePathError("stat", name, os.ErrNotExist)
+}
+
+func (f *FileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean(name)
+	if !f.dirs[name] {
+		return nil, fakePathError("open", name, os.ErrNotExist)
+	}
+	var entries []os.DirEntry
+	for d := range f.dirs {
+		if d != name && path.Dir(d) == name {
+			entries = append(entries, &fakeFileInfo{name: path.Base(d), dir: true})
+		}
+	}
+	for file, data := range f.files {
+		if path.Dir(file) == name {
+			entries = append(entries, &fakeFileInfo{name: path.Base(file), size: int64(len(data))})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// fakeFileInfo is both the os.FileInfo and the os.DirEntry of the fake file system
+type fakeFileInfo struct {
+	name string
+	dir  bool
+	size int64
+}
+
+func (i *fakeFileInfo) Name() string { return i.name }
+func (i *fakeFileInfo) Size() int64  { return i.size }
+func (i *fakeFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (i *fakeFileInfo) ModTime() time.Time         { return time.Time{} }
+func (i *fakeFileInfo) IsDir() bool                { return i.dir }
+func (i *fakeFileInfo) Sys() interface{}           { return nil }
+func (i *fakeFileInfo) Type() os.FileMode          { return i.Mode().Type() }
+func (i *fakeFileInfo) Info() (os.FileInfo, error) { return i, nil }
@@ -0,0 +1,83 @@
+package subsystem
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+)
+
+type FreezerState string
+
+const (
+	Frozen FreezerState = "FROZEN"
+	Thawed FreezerState = "THAWED"
+	// Freezing is the state of the cgroup not settled yet after a freeze or thaw
+	Freezing FreezerState = "FREEZING"
+)
+
+// FreezeTimeout is how long to wait the freezer state to settle
+var FreezeTimeout = 10 * time.Second
+
+// Freezer is the subsystem which can suspend and resume all the processes of the cgroup,
+// the state is asked without waiting so the caller decide how to wait for it
+type Freezer interface {
+	SetFreezerState(path string, state FreezerState) error
+	GetFreezerState(path string) (FreezerState, error)
+}
+
+type FreezerSubSystem struct {
+}
+
+// the freezer has no resource limit,only create the cgroup
+func (s *FreezerSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+// SetFreezerState write the state to freezer.state,the cgroup is FREEZING until all the processes are frozen
+func (s *FreezerSubSystem) SetFreezerState(cgroupPath string, state FreezerState) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if err := writeCgroupFile(subsysCgroupPath, "freezer.state", string(state)); err != nil {
+		return fmt.Errorf("set cgroup freezer state fail %v", err)
+	}
+	return nil
+}
+
+func (s *FreezerSubSystem) GetFreezerState(cgroupPath string) (FreezerState, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return "", err
+	}
+	current, err := readCgroupFile(subsysCgroupPath, "freezer.state")
+	if err != nil {
+		return "", fmt.Errorf("read cgroup freezer state fail %v", err)
+	}
+	return FreezerState(current), nil
+}
+
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
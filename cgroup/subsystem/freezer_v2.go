@@ -0,0 +1,73 @@
+package subsystem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FreezerSubSystemV2 use the cgroup.freeze of the unified hierarchy,it is not a controller
+type FreezerSubSystemV2 struct {
+}
+
+func (s *FreezerSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupV2Path("", cgroupPath, true)
+	return err
+}
+
+func (s *FreezerSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *FreezerSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+// SetFreezerState write the cgroup.freeze,the "frozen" of cgroup.events follow it when the change settle
+func (s *FreezerSubSystemV2) SetFreezerState(cgroupPath string, state FreezerState) error {
+	subsysCgroupPath, err := GetCgroupV2Path("", cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	value := "0"
+	if state == Frozen {
+		value = "1"
+	}
+	if err := writeCgroupFile(subsysCgroupPath, "cgroup.freeze", value); err != nil {
+		return fmt.Errorf("set cgroup freeze fail %v", err)
+	}
+	return nil
+}
+
+// GetFreezerState compare the asked cgroup.freeze with the "frozen" of cgroup.events,
+// the cgroup is Freezing while they differ
+func (s *FreezerSubSystemV2) GetFreezerState(cgroupPath string) (FreezerState, error) {
+	subsysCgroupPath, err := GetCgroupV2Path("", cgroupPath, false)
+	if err != nil {
+		return "", err
+	}
+	asked, err := readCgroupFile(subsysCgroupPath, "cgroup.freeze")
+	if err != nil {
+		return "", fmt.Errorf("read cgroup freeze fail %v", err)
+	}
+	events, err := readCgroupFile(subsysCgroupPath, "cgroup.events")
+	if err != nil {
+		return "", fmt.Errorf("read cgroup events fail %v", err)
+	}
+	frozen := ""
+	for _, line := range strings.Split(events, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "frozen" {
+			frozen = fields[1]
+		}
+	}
+	switch {
+	case asked != frozen:
+		return Freezing, nil
+	case frozen == "1":
+		return Frozen, nil
+	}
+	return Thawed, nil
+}
+
+func (s *FreezerSubSystemV2) Name() string {
+	return "freezer"
+}
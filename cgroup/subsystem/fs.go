@@ -0,0 +1,76 @@
+package subsystem
+
+import (
+	"io"
+	"os"
+	"path"
+)
+
+// FileSystem is what the cgroup layer need from the cgroup file system,
+// all the cgroup files are read and written through it
+type FileSystem interface {
+	// Open return the real file for the kernel interfaces need a fd,like the oom eventfd
+	Open(name string) (*os.File, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	MkdirAll(dir string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+}
+
+var (
+	// Root is joined before every mountpoint found in the mountinfo,
+	// so the cgroup layer can work on a hierarchy mounted somewhere else
+	Root = ""
+	// FS is the file system the cgroup files are accessed through
+	FS FileSystem = osFileSystem{}
+	// OpenMountInfo open the mountinfo the cgroup mountpoints are found in
+	OpenMountInfo = func() (io.ReadCloser, error) {
+		return os.Open("/proc/self/mountinfo")
+	}
+)
+
+// the mountpoint in the mountinfo joined with the Root
+func rootedMountpoint(mountpoint string) string {
+	if Root == "" {
+		return mountpoint
+	}
+	return path.Join(Root, mountpoint)
+}
+
+// osFileSystem is the real file system
+type osFileSystem struct{}
+
+func (osFileSystem) Open(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFileSystem) MkdirAll(dir string, perm os.FileMode) error {
+	return os.MkdirAll(dir, perm)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
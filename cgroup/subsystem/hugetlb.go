@@ -0,0 +1,160 @@
+package subsystem
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HugepageLimit is the limit of one huge page size,like 2MB:512m
+type HugepageLimit struct {
+	// the page size named by the kernel,like 2MB or 1GB
+	PageSize string `json:"pageSize"`
+	// the limit in bytes
+	Limit uint64 `json:"limit"`
+}
+
+type HugetlbSubSystem struct {
+}
+
+func (s *HugetlbSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	return setHugetlbLimit(subsysCgroupPath, res, "limit_in_bytes")
+}
+
+func (s *HugetlbSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *HugetlbSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+func (s *HugetlbSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	pageSizes, err := HugePageSizes(subsysCgroupPath, "limit_in_bytes")
+	if err != nil {
+		return err
+	}
+	stats.Hugetlb = make(map[string]HugetlbStats)
+	for _, pageSize := range pageSizes {
+		var hugetlbStats HugetlbStats
+		prefix := "hugetlb." + pageSize + "."
+		if hugetlbStats.Usage, err = readCgroupUint(subsysCgroupPath, prefix+"usage_in_bytes"); err != nil {
+			return err
+		}
+		if hugetlbStats.MaxUsage, err = readCgroupUint(subsysCgroupPath, prefix+"max_usage_in_bytes"); err != nil {
+			return err
+		}
+		if hugetlbStats.Failcnt, err = readCgroupUint(subsysCgroupPath, prefix+"failcnt"); err != nil {
+			return err
+		}
+		stats.Hugetlb[pageSize] = hugetlbStats
+	}
+	return nil
+}
+
+func (s *HugetlbSubSystem) Name() string {
+	return "hugetlb"
+}
+
+// write every limit to the hugetlb.<size>.<limitFile>,the page size must be supported by the host
+func setHugetlbLimit(subsysCgroupPath string, res *ResourceConfig, limitFile string) error {
+	if len(res.HugetlbLimit) == 0 {
+		return nil
+	}
+	pageSizes, err := HugePageSizes(subsysCgroupPath, limitFile)
+	if err != nil {
+		return err
+	}
+	for _, hugetlb := range res.HugetlbLimit {
+		supported := false
+		for _, pageSize := range pageSizes {
+			if pageSize == hugetlb.PageSize {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("hugetlb page size %s is not supported,the supported are %s", hugetlb.PageSize, strings.Join(pageSizes, ","))
+		}
+		file := "hugetlb." + hugetlb.PageSize + "." + limitFile
+		if err := writeCgroupFile(subsysCgroupPath, file, strconv.FormatUint(hugetlb.Limit, 10)); err != nil {
+			return fmt.Errorf("set cgroup hugetlb %s fail %v", hugetlb.PageSize, err)
+		}
+	}
+	return nil
+}
+
+// HugePageSizes discover the page sizes supported by the host from the
+// hugetlb.<size>.<limitFile> files of the cgroup
+func HugePageSizes(subsysCgroupPath, limitFile string) ([]string, error) {
+	entries, err := FS.ReadDir(subsysCgroupPath)
+	if err != nil {
+		return nil, fmt.Errorf("read cgroup dir %s fail %v", subsysCgroupPath, err)
+	}
+	var pageSizes []string
+	suffix := "." + limitFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "hugetlb.") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		pageSize := strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), suffix)
+		//skip the files like hugetlb.2MB.rsvd.max
+		if !strings.Contains(pageSize, ".") {
+			pageSizes = append(pageSizes, pageSize)
+		}
+	}
+	sort.Strings(pageSizes)
+	return pageSizes, nil
+}
+
+// ParseHugetlbLimit parse the "2MB:512m" into the limit of the huge page size
+func ParseHugetlbLimit(spec string) (*HugepageLimit, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid hugetlb limit %s,the format is <page-size>:<limit>", spec)
+	}
+	pageSize, err := RAMInBytes(parts[0])
+	if err != nil || pageSize == 0 {
+		return nil, fmt.Errorf("invalid hugetlb page size %s", parts[0])
+	}
+	limit, err := RAMInBytes(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hugetlb limit %s", parts[1])
+	}
+	return &HugepageLimit{PageSize: hugePageSizeName(pageSize), Limit: uint64(limit)}, nil
+}
+
+// the kernel name the page size like 64KB,2MB,1GB
+func hugePageSizeName(size int64) string {
+	switch {
+	case size >= GiB && size%GiB == 0:
+		return fmt.Sprintf("%dGB", size/GiB)
+	case size >= MiB && size%MiB == 0:
+		return fmt.Sprintf("%dMB", size/MiB)
+	case size >= KiB && size%KiB == 0:
+		return fmt.Sprintf("%dKB", size/KiB)
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,92 @@
+package subsystem
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHugePageSizes(t *testing.T) {
+	useFakeCgroupV2(t, map[string]string{
+		"hugetlb.2MB.max":            "max",
+		"hugetlb.2MB.rsvd.max":       "max",
+		"hugetlb.2MB.current":        "0",
+		"hugetlb.1GB.max":            "max",
+		"hugetlb.1GB.rsvd.max":       "max",
+		"hugetlb.1GB.current":        "0",
+		"hugetlb.2MB.limit_in_bytes": "0",
+	})
+	dir, err := GetCgroupV2Path("hugetlb", "mydocker/abc", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the rsvd files are not a page size
+	pageSizes, err := HugePageSizes(dir, "max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1GB", "2MB"}; !reflect.DeepEqual(pageSizes, want) {
+		t.Errorf("page sizes %v,want %v", pageSizes, want)
+	}
+	if pageSizes, err = HugePageSizes(dir, "limit_in_bytes"); err != nil || !reflect.DeepEqual(pageSizes, []string{"2MB"}) {
+		t.Errorf("v1 page sizes %v,%v", pageSizes, err)
+	}
+	if _, err := HugePageSizes("/sys/fs/cgroup/missing", "max"); err == nil {
+		t.Error("page sizes of a missing cgroup succeeded")
+	}
+}
+
+func TestHugetlbSubSystemV2(t *testing.T) {
+	fs := useFakeCgroupV2(t, map[string]string{
+		"hugetlb.2MB.max":      "max",
+		"hugetlb.2MB.rsvd.max": "max",
+		"hugetlb.2MB.current":  "4194304",
+		"hugetlb.2MB.events":   "max 3",
+	})
+	hugetlb := &HugetlbSubSystemV2{}
+	res := &ResourceConfig{HugetlbLimit: []*HugepageLimit{{PageSize: "2MB", Limit: 8 * uint64(MiB)}}}
+	if err := hugetlb.Set("mydocker/abc", res); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/abc/hugetlb.2MB.max"); got != "8388608" {
+		t.Errorf("hugetlb.2MB.max = %q,want 8388608", got)
+	}
+	res = &ResourceConfig{HugetlbLimit: []*HugepageLimit{{PageSize: "1GB", Limit: uint64(GiB)}}}
+	if err := hugetlb.Set("mydocker/abc", res); err == nil || !strings.Contains(err.Error(), "the supported are 2MB") {
+		t.Errorf("set an unsupported page size error %v", err)
+	}
+	var stats Stats
+	if err := hugetlb.GetStats("mydocker/abc", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]HugetlbStats{"2MB": {Usage: 4194304, Failcnt: 3}}; !reflect.DeepEqual(stats.Hugetlb, want) {
+		t.Errorf("hugetlb stats %+v,want %+v", stats.Hugetlb, want)
+	}
+}
+
+func TestParseHugetlbLimit(t *testing.T) {
+	tests := []struct {
+		spec string
+		want HugepageLimit
+	}{
+		{"2MB:512m", HugepageLimit{PageSize: "2MB", Limit: 512 * uint64(MiB)}},
+		{"2m:1g", HugepageLimit{PageSize: "2MB", Limit: uint64(GiB)}},
+		{"1GB:2g", HugepageLimit{PageSize: "1GB", Limit: 2 * uint64(GiB)}},
+		{"64kb:0", HugepageLimit{PageSize: "64KB", Limit: 0}},
+	}
+	for _, test := range tests {
+		got, err := ParseHugetlbLimit(test.spec)
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		if *got != test.want {
+			t.Errorf("%q = %+v,want %+v", test.spec, *got, test.want)
+		}
+	}
+	for _, spec := range []string{"", "2MB", "2MB:", ":512m", "0:512m", "2MB:x", "2MB:-1"} {
+		if _, err := ParseHugetlbLimit(spec); err == nil {
+			t.Errorf("%q: want error", spec)
+		}
+	}
+}
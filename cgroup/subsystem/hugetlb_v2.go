@@ -0,0 +1,52 @@
+package subsystem
+
+// HugetlbSubSystemV2 is the hugetlb controller of the unified hierarchy
+type HugetlbSubSystemV2 struct {
+}
+
+func (s *HugetlbSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	return setHugetlbLimit(subsysCgroupPath, res, "max")
+}
+
+func (s *HugetlbSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *HugetlbSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+// v2 has no max usage,the failcnt is the "max" of hugetlb.<size>.events
+func (s *HugetlbSubSystemV2) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	pageSizes, err := HugePageSizes(subsysCgroupPath, "max")
+	if err != nil {
+		return err
+	}
+	stats.Hugetlb = make(map[string]HugetlbStats)
+	for _, pageSize := range pageSizes {
+		var hugetlbStats HugetlbStats
+		prefix := "hugetlb." + pageSize + "."
+		if hugetlbStats.Usage, err = readCgroupUint(subsysCgroupPath, prefix+"current"); err != nil {
+			return err
+		}
+		events, err := readCgroupKeyValues(subsysCgroupPath, prefix+"events")
+		if err != nil {
+			return err
+		}
+		hugetlbStats.Failcnt = events["max"]
+		stats.Hugetlb[pageSize] = hugetlbStats
+	}
+	return nil
+}
+
+func (s *HugetlbSubSystemV2) Name() string {
+	return "hugetlb"
+}
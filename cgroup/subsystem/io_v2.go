@@ -0,0 +1,107 @@
+package subsystem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IoSubSystemV2 is the io controller of the unified hierarchy,it take the place of the v1 blkio
+type IoSubSystemV2 struct {
+}
+
+func (s *IoSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.BlkioWeight != 0 {
+		weight := fmt.Sprintf("default %d", blkioWeightToIOWeight(uint64(res.BlkioWeight)))
+		if err := writeCgroupFile(subsysCgroupPath, "io.weight", weight); err != nil {
+			return fmt.Errorf("set cgroup io weight fail %v", err)
+		}
+	}
+	for _, wd := range res.BlkioWeightDevice {
+		weight := fmt.Sprintf("%d:%d %d", wd.Major, wd.Minor, blkioWeightToIOWeight(uint64(wd.Weight)))
+		if err := writeCgroupFile(subsysCgroupPath, "io.weight", weight); err != nil {
+			return fmt.Errorf("set cgroup io weight device fail %v", err)
+		}
+	}
+	//io.max take all the limits of one device in one line,like "8:0 rbps=1048576 wiops=100"
+	var devices []string
+	limits := make(map[string][]string)
+	addLimits := func(key string, throttles []*ThrottleDevice) {
+		for _, td := range throttles {
+			device := fmt.Sprintf("%d:%d", td.Major, td.Minor)
+			if _, ok := limits[device]; !ok {
+				devices = append(devices, device)
+			}
+			limits[device] = append(limits[device], fmt.Sprintf("%s=%d", key, td.Rate))
+		}
+	}
+	addLimits("rbps", res.BlkioDeviceReadBps)
+	addLimits("wbps", res.BlkioDeviceWriteBps)
+	addLimits("riops", res.BlkioDeviceReadIOps)
+	addLimits("wiops", res.BlkioDeviceWriteIOps)
+	for _, device := range devices {
+		line := device + " " + strings.Join(limits[device], " ")
+		if err := writeCgroupFile(subsysCgroupPath, "io.max", line); err != nil {
+			return fmt.Errorf("set cgroup io max fail %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *IoSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *IoSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *IoSubSystemV2) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	//every line is like "8:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0"
+	content, err := readCgroupFile(subsysCgroupPath, "io.stat")
+	if err != nil {
+		return fmt.Errorf("read io stat fail %v", err)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		for _, field := range strings.Fields(line) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				stats.Blkio.ReadBytes += n
+			case "wbytes":
+				stats.Blkio.WriteBytes += n
+			}
+		}
+	}
+	if stats.Blkio.Pressure, err = readPressure(subsysCgroupPath, "io.pressure"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *IoSubSystemV2) Name() string {
+	return "io"
+}
+
+// map the v1 blkio.weight [10,1000] to the v2 io.weight [1,10000]
+func blkioWeightToIOWeight(weight uint64) uint64 {
+	if weight < 10 {
+		weight = 10
+	}
+	return 1 + (weight-10)*9999/990
+}
@@ -0,0 +1,87 @@
+package subsystem
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MemorySubSystemV2 is the memory controller of the unified hierarchy
+type MemorySubSystemV2 struct {
+}
+
+func (s *MemorySubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.MemorySwappiness != nil {
+		return fmt.Errorf("memory swappiness is not supported by cgroup v2")
+	}
+	if res.MemoryLimit != 0 {
+		if err := writeCgroupFile(subsysCgroupPath, "memory.max", memoryValueV2(res.MemoryLimit)); err != nil {
+			return fmt.Errorf("set cgroup memory fail %v", err)
+		}
+	}
+	//the memory.swap.max only limit the swap,not the total of memory and swap as v1
+	if res.MemorySwap != 0 {
+		swap := int64(-1)
+		if res.MemorySwap > 0 {
+			swap = res.MemorySwap - res.MemoryLimit
+		}
+		if err := writeCgroupFile(subsysCgroupPath, "memory.swap.max", memoryValueV2(swap)); err != nil {
+			return fmt.Errorf("set cgroup memory swap fail %v", err)
+		}
+	}
+	if res.MemoryReservation != 0 {
+		if err := writeCgroupFile(subsysCgroupPath, "memory.low", memoryValueV2(res.MemoryReservation)); err != nil {
+			return fmt.Errorf("set cgroup memory reservation fail %v", err)
+		}
+	}
+	if res.MemoryHigh != 0 {
+		if err := writeCgroupFile(subsysCgroupPath, "memory.high", memoryValueV2(res.MemoryHigh)); err != nil {
+			return fmt.Errorf("set cgroup memory high fail %v", err)
+		}
+	}
+	return nil
+}
+
+// v1 use -1 as unlimited,v2 use max
+func memoryValueV2(value int64) string {
+	if value == -1 {
+		return "max"
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func (s *MemorySubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *MemorySubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *MemorySubSystemV2) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Memory.Usage, err = readCgroupUint(subsysCgroupPath, "memory.current"); err != nil {
+		return err
+	}
+	if stats.Memory.Limit, err = readCgroupUint(subsysCgroupPath, "memory.max"); err != nil {
+		return err
+	}
+	//memory.peak is only provided since linux 5.19
+	if peak, err := readCgroupUint(subsysCgroupPath, "memory.peak"); err == nil {
+		stats.Memory.MaxUsage = peak
+	}
+	if stats.Memory.Pressure, err = readPressure(subsysCgroupPath, "memory.pressure"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MemorySubSystemV2) Name() string {
+	return "memory"
+}
@@ -0,0 +1,143 @@
+package subsystem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path"
+	"syscall"
+)
+
+// OOMNotifier is the subsystem which can report the oom kill of the cgroup
+type OOMNotifier interface {
+	// NotifyOOM return a channel receiving a value after the oom kill,the kills happened
+	// before the value is received are merged into it,it is closed when the cgroup is removed
+	NotifyOOM(path string) (<-chan struct{}, error)
+	// OOMKillCount return the number of the processes killed by the oom killer
+	OOMKillCount(path string) (uint64, error)
+}
+
+// register an eventfd to the cgroup.event_control,the eventfd is readable on every oom
+// and also when the cgroup is removed
+func (s *MemorySubSystem) NotifyOOM(cgroupPath string) (<-chan struct{}, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	oomControl, err := FS.Open(path.Join(subsysCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return nil, fmt.Errorf("open memory oom control fail %v", err)
+	}
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		oomControl.Close()
+		return nil, fmt.Errorf("create eventfd fail %v", err)
+	}
+	eventFile := os.NewFile(uintptr(efd), "oom-eventfd")
+	control := fmt.Sprintf("%d %d", efd, oomControl.Fd())
+	if err := writeCgroupFile(subsysCgroupPath, "cgroup.event_control", control); err != nil {
+		eventFile.Close()
+		oomControl.Close()
+		return nil, fmt.Errorf("register oom event fail %v", err)
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			close(ch)
+			eventFile.Close()
+			oomControl.Close()
+		}()
+		buf := make([]byte, 8)
+		for {
+			if _, err := eventFile.Read(buf); err != nil {
+				return
+			}
+			//the eventfd is also notified when the cgroup is removed
+			if _, err := FS.Stat(path.Join(subsysCgroupPath, "memory.oom_control")); err != nil {
+				return
+			}
+			if binary.LittleEndian.Uint64(buf) > 0 {
+				notifyOOMKilled(ch)
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// never block the watcher on a slow receiver,a pending value already tell the oom kill
+func notifyOOMKilled(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// the oom_kill of memory.oom_control is only provided since linux 4.13
+func (s *MemorySubSystem) OOMKillCount(cgroupPath string) (uint64, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	oomControl, err := readCgroupKeyValues(subsysCgroupPath, "memory.oom_control")
+	if err != nil {
+		return 0, err
+	}
+	return oomControl["oom_kill"], nil
+}
+
+// watch the memory.events by inotify,the oom_kill counter is increased on every oom kill
+func (s *MemorySubSystemV2) NotifyOOM(cgroupPath string) (<-chan struct{}, error) {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.OOMKillCount(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("init inotify fail %v", err)
+	}
+	inotifyFile := os.NewFile(uintptr(fd), "oom-inotify")
+	if _, err := syscall.InotifyAddWatch(fd, path.Join(subsysCgroupPath, "memory.events"), syscall.IN_MODIFY); err != nil {
+		inotifyFile.Close()
+		return nil, fmt.Errorf("watch memory events fail %v", err)
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			close(ch)
+			inotifyFile.Close()
+		}()
+		buf := make([]byte, syscall.SizeofInotifyEvent*16)
+		for {
+			if _, err := inotifyFile.Read(buf); err != nil {
+				return
+			}
+			//the watch is gone with the cgroup,read the counter fail at that time
+			current, err := s.OOMKillCount(cgroupPath)
+			if err != nil {
+				return
+			}
+			if current > count {
+				count = current
+				notifyOOMKilled(ch)
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *MemorySubSystemV2) OOMKillCount(cgroupPath string) (uint64, error) {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	events, err := readCgroupKeyValues(subsysCgroupPath, "memory.events")
+	if err != nil {
+		return 0, err
+	}
+	return events["oom_kill"], nil
+}
@@ -0,0 +1,74 @@
+package subsystem
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+)
+
+type PidsSubSystem struct {
+}
+
+func (s *PidsSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if res.PidsLimit != 0 {
+			if err := writeCgroupFile(subsysCgroupPath, "pids.max", pidsLimitValue(res.PidsLimit)); err != nil {
+				return fmt.Errorf("set cgroup pids limit fail %v", err)
+			}
+		}
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return FS.Remove(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+}
+
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return readPidsStats(subsysCgroupPath, stats)
+}
+
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+// the pids.max of the cgroup,-1 means no limit
+func pidsLimitValue(limit int64) string {
+	if limit < 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// v1 and v2 share the same pids.current and pids.max
+func readPidsStats(subsysCgroupPath string, stats *Stats) error {
+	var err error
+	if stats.Pids.Current, err = readCgroupUint(subsysCgroupPath, "pids.current"); err != nil {
+		return err
+	}
+	if stats.Pids.Limit, err = readCgroupUint(subsysCgroupPath, "pids.max"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"testing"
+)
+
+func TestPidsLimitValue(t *testing.T) {
+	tests := []struct {
+		limit int64
+		want  string
+	}{
+		{-1, "max"},
+		{1, "1"},
+		{100, "100"},
+	}
+	for _, test := range tests {
+		if got := pidsLimitValue(test.limit); got != test.want {
+			t.Errorf("%d = %q,want %q", test.limit, got, test.want)
+		}
+	}
+}
+
+func TestPidsSubSystemV1(t *testing.T) {
+	fs := fakefs.New(map[string]string{"pids.current": "3", "pids.max": "max", "tasks": ""})
+	if err := fs.MkdirAll("/sys/fs/cgroup/pids", 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldFS, oldMountInfo := FS, OpenMountInfo
+	FS = fs
+	OpenMountInfo = fakefs.MountInfo("34 25 0:30 / /sys/fs/cgroup/pids rw,nosuid,nodev,noexec,relatime shared:13 - cgroup cgroup rw,pids\n")
+	t.Cleanup(func() {
+		FS, OpenMountInfo = oldFS, oldMountInfo
+	})
+	pids := &PidsSubSystem{}
+	dir := "/sys/fs/cgroup/pids/mydocker/abc/"
+
+	//no limit keep the max of the new cgroup
+	if err := pids.Set("mydocker/abc", &ResourceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	if err := pids.GetStats("mydocker/abc", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pids.Current != 3 || stats.Pids.Limit != 0 {
+		t.Errorf("unlimited pids stats %+v", stats.Pids)
+	}
+	if err := pids.Set("mydocker/abc", &ResourceConfig{PidsLimit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, dir+"pids.max"); got != "100" {
+		t.Errorf("pids.max = %q,want 100", got)
+	}
+	if err := pids.GetStats("mydocker/abc", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pids.Limit != 100 {
+		t.Errorf("pids limit %d,want 100", stats.Pids.Limit)
+	}
+	if err := pids.Apply("mydocker/abc", 1234); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, dir+"tasks"); got != "1234" {
+		t.Errorf("tasks = %q,want 1234", got)
+	}
+}
+
+func TestPidsSubSystemV2(t *testing.T) {
+	fs := useFakeCgroupV2(t, map[string]string{"pids.current": "1", "pids.max": "max"})
+	pids := &PidsSubSystemV2{}
+	if err := pids.Set("mydocker/abc", &ResourceConfig{PidsLimit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/abc/pids.max"); got != "50" {
+		t.Errorf("pids.max = %q,want 50", got)
+	}
+	//the limit is lifted by update
+	if err := pids.Set("mydocker/abc", &ResourceConfig{PidsLimit: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/abc/pids.max"); got != "max" {
+		t.Errorf("pids.max = %q,want max", got)
+	}
+	if got := readTestFile(t, fs, "/sys/fs/cgroup/mydocker/cgroup.subtree_control"); got != "pids" {
+		t.Errorf("subtree control %q,want pids", got)
+	}
+	var stats Stats
+	if err := pids.GetStats("mydocker/abc", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pids.Current != 1 || stats.Pids.Limit != 0 {
+		t.Errorf("pids stats %+v", stats.Pids)
+	}
+	if err := fs.Remove("/sys/fs/cgroup/mydocker/abc/pids.current"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pids.GetStats("mydocker/abc", &stats); err == nil {
+		t.Error("get stats without pids.current succeeded")
+	}
+}
@@ -0,0 +1,40 @@
+package subsystem
+
+import "fmt"
+
+// PidsSubSystemV2 is the pids controller of the unified hierarchy
+type PidsSubSystemV2 struct {
+}
+
+func (s *PidsSubSystemV2) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.PidsLimit != 0 {
+		if err := writeCgroupFile(subsysCgroupPath, "pids.max", pidsLimitValue(res.PidsLimit)); err != nil {
+			return fmt.Errorf("set cgroup pids limit fail %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *PidsSubSystemV2) Remove(cgroupPath string) error {
+	return removeV2(cgroupPath)
+}
+
+func (s *PidsSubSystemV2) Apply(cgroupPath string, pid int) error {
+	return applyV2(cgroupPath, pid)
+}
+
+func (s *PidsSubSystemV2) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupV2Path(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return readPidsStats(subsysCgroupPath, stats)
+}
+
+func (s *PidsSubSystemV2) Name() string {
+	return "pids"
+}
@@ -0,0 +1,80 @@
+package subsystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PSIData is the percentage of the time the tasks stalled in the last 10,60 and 300 seconds,
+// and the total stall time in microseconds
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// PressureStats is read from the cpu.pressure,memory.pressure or io.pressure of the cgroup,
+// "some" means at least one task stalled and "full" means all the non-idle tasks stalled together
+type PressureStats struct {
+	Some PSIData `json:"some"`
+	Full PSIData `json:"full"`
+}
+
+// read the pressure file of the cgroup,nil is returned when the kernel doesn't provide psi
+func readPressure(cgroupDir, file string) (*PressureStats, error) {
+	content, err := readCgroupFile(cgroupDir, file)
+	if err != nil {
+		//the file is missing without CONFIG_PSI and can not be read with psi=0
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s fail %v", file, err)
+	}
+	pressure := &PressureStats{}
+	//every line is like "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var data *PSIData
+		switch fields[0] {
+		case "some":
+			data = &pressure.Some
+		case "full":
+			data = &pressure.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			if key == "total" {
+				if data.Total, err = strconv.ParseUint(value, 10, 64); err != nil {
+					return nil, fmt.Errorf("parse %s of %s fail %v", field, file, err)
+				}
+				continue
+			}
+			avg, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s of %s fail %v", field, file, err)
+			}
+			switch key {
+			case "avg10":
+				data.Avg10 = avg
+			case "avg60":
+				data.Avg60 = avg
+			case "avg300":
+				data.Avg300 = avg
+			}
+		}
+	}
+	return pressure, nil
+}
@@ -0,0 +1,98 @@
+package subsystem
+
+import (
+	"docker-my/cgroup/subsystem/fakefs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// psiDisabledFS fail the read of the pressure files like the kernel booted with psi=0
+type psiDisabledFS struct {
+	*fakefs.FileSystem
+}
+
+func (f psiDisabledFS) ReadFile(name string) ([]byte, error) {
+	if strings.HasSuffix(name, ".pressure") {
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EOPNOTSUPP}
+	}
+	return f.FileSystem.ReadFile(name)
+}
+
+func TestReadPressure(t *testing.T) {
+	fs := useFakeCgroupV2(t, nil)
+	dir := "/sys/fs/cgroup"
+	fs.WriteFile(path.Join(dir, "cpu.pressure"), []byte("some avg10=1.50 avg60=0.25 avg300=0.00 total=12345\nfull avg10=0.50 avg60=0.00 avg300=0.00 total=678\n"), 0644)
+	pressure, err := readPressure(dir, "cpu.pressure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PressureStats{
+		Some: PSIData{Avg10: 1.5, Avg60: 0.25, Total: 12345},
+		Full: PSIData{Avg10: 0.5, Total: 678},
+	}
+	if pressure == nil || *pressure != want {
+		t.Errorf("pressure %+v,want %+v", pressure, want)
+	}
+
+	//the kernel without CONFIG_PSI has no pressure file
+	if pressure, err := readPressure(dir, "memory.pressure"); err != nil || pressure != nil {
+		t.Errorf("missing pressure file = %+v,%v,want nil", pressure, err)
+	}
+	fs.WriteFile(path.Join(dir, "io.pressure"), []byte("some avg10=x avg60=0.00 avg300=0.00 total=0\n"), 0644)
+	if _, err := readPressure(dir, "io.pressure"); err == nil {
+		t.Error("malformed pressure file succeeded")
+	}
+
+	FS = psiDisabledFS{fs}
+	if pressure, err := readPressure(dir, "cpu.pressure"); err != nil || pressure != nil {
+		t.Errorf("pressure with psi disabled = %+v,%v,want nil", pressure, err)
+	}
+}
+
+func TestGetStatsWithoutPressure(t *testing.T) {
+	useFakeCgroupV2(t, map[string]string{
+		"memory.current": "4096",
+		"memory.max":     "max",
+		"cpu.stat":       "usage_usec 5",
+		"io.stat":        "",
+	})
+	if _, err := GetCgroupV2Path("", "mydocker/abc", true); err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	for _, subSysIns := range []StatsSubSystem{&MemorySubSystemV2{}, &CpuSubSystemV2{}, &IoSubSystemV2{}} {
+		if err := subSysIns.GetStats("mydocker/abc", &stats); err != nil {
+			t.Errorf("%T: %v", subSysIns, err)
+		}
+	}
+	if stats.Memory.Usage != 4096 || stats.Cpu.UsageNanos != 5000 {
+		t.Errorf("stats memory %+v,cpu %+v", stats.Memory, stats.Cpu)
+	}
+	if stats.Memory.Pressure != nil || stats.Cpu.Pressure != nil || stats.Blkio.Pressure != nil {
+		t.Error("pressure is set without the pressure files")
+	}
+}
+
+func TestMemorySubSystemV2High(t *testing.T) {
+	fs := useFakeCgroupV2(t, nil)
+	memory := &MemorySubSystemV2{}
+	if err := memory.Set("mydocker/abc", &ResourceConfig{MemoryLimit: 100 * MiB, MemoryHigh: 80 * MiB}); err != nil {
+		t.Fatal(err)
+	}
+	dir := "/sys/fs/cgroup/mydocker/abc/"
+	if got := readTestFile(t, fs, dir+"memory.high"); got != "83886080" {
+		t.Errorf("memory.high = %q,want 83886080", got)
+	}
+	if got := readTestFile(t, fs, dir+"memory.max"); got != "104857600" {
+		t.Errorf("memory.max = %q,want 104857600", got)
+	}
+	if err := memory.Set("mydocker/abc", &ResourceConfig{MemoryHigh: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, fs, dir+"memory.high"); got != "max" {
+		t.Errorf("memory.high = %q,want max", got)
+	}
+}
@@ -0,0 +1,92 @@
+package subsystem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stats is the resource usage read from the cgroup of a container
+type Stats struct {
+	Cpu    CpuStats    `json:"cpu"`
+	Memory MemoryStats `json:"memory"`
+	Pids   PidsStats   `json:"pids"`
+	Blkio  BlkioStats  `json:"blkio"`
+	// keyed by the page size,like 2MB
+	Hugetlb map[string]HugetlbStats `json:"hugetlb,omitempty"`
+}
+
+type CpuStats struct {
+	// total cpu time used by the cgroup
+	UsageNanos uint64 `json:"usageNanos"`
+	// only provided by v2
+	Pressure *PressureStats `json:"pressure,omitempty"`
+}
+
+type MemoryStats struct {
+	Usage uint64 `json:"usage"`
+	// the peak of the usage,0 when the kernel doesn't record it
+	MaxUsage uint64 `json:"maxUsage,omitempty"`
+	// 0 means no limit
+	Limit uint64 `json:"limit"`
+	// only provided by v2
+	Pressure *PressureStats `json:"pressure,omitempty"`
+}
+
+type PidsStats struct {
+	// number of the processes in the cgroup
+	Current uint64 `json:"current"`
+	// the pids.max of the cgroup,0 means no limit
+	Limit uint64 `json:"limit"`
+}
+
+type BlkioStats struct {
+	// bytes read from and written to all the block devices
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+	// the io.pressure,only provided by v2
+	Pressure *PressureStats `json:"pressure,omitempty"`
+}
+
+type HugetlbStats struct {
+	Usage uint64 `json:"usage"`
+	// only provided by v1
+	MaxUsage uint64 `json:"maxUsage,omitempty"`
+	// number of the allocations fail for the limit
+	Failcnt uint64 `json:"failcnt"`
+}
+
+// read a file only contain one number,"max" means no limit and is read as 0
+func readCgroupUint(cgroupDir, file string) (uint64, error) {
+	content, err := readCgroupFile(cgroupDir, file)
+	if err != nil {
+		return 0, fmt.Errorf("read %s fail %v", file, err)
+	}
+	if content == "max" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s of %s fail %v", content, file, err)
+	}
+	return value, nil
+}
+
+// read the "key value" lines of a file like cpu.stat or memory.stat
+func readCgroupKeyValues(cgroupDir, file string) (map[string]uint64, error) {
+	content, err := readCgroupFile(cgroupDir, file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s fail %v", file, err)
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, nil
+}
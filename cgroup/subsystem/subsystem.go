@@ -2,22 +2,130 @@ package subsystem
 
 import (
 	"bufio"
-	"docker-my/container"
 	"fmt"
-	log "github.com/sirupsen/logrus"
 	"os"
-	"os/exec"
 	"path"
-	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
 )
 
 type ResourceConfig struct {
-	MemoryLimit string
-	CpuShare    string
-	CpuSet      string
+	//the memory limits in bytes,-1 means no limit
+	MemoryLimit int64 `json:"memoryLimit,omitempty"`
+	//the total of the memory and the swap,just like docker
+	MemorySwap        int64 `json:"memorySwap,omitempty"`
+	MemoryReservation int64 `json:"memoryReservation,omitempty"`
+	//the processes are throttled and reclaimed above it,only provided by v2
+	MemoryHigh int64 `json:"memoryHigh,omitempty"`
+	//from 0 to 100,nil means not set since 0 is a valid value
+	MemorySwappiness *int64 `json:"memorySwappiness,omitempty"`
+	CpuShare         string `json:"cpuShare,omitempty"`
+	CpuSet           string `json:"cpuSet,omitempty"`
+	//the numa memory nodes can be used,like 0-1
+	CpuSetMems string `json:"cpuSetMems,omitempty"`
+	//the cfs period in microseconds and the cpu time can be used in a period,-1 means no limit
+	CpuPeriod uint64 `json:"cpuPeriod,omitempty"`
+	CpuQuota  int64  `json:"cpuQuota,omitempty"`
+	//max number of processes,-1 means no limit
+	PidsLimit int64 `json:"pidsLimit,omitempty"`
+	//relative weight of the block io,from 10 to 1000
+	BlkioWeight          uint16            `json:"blkioWeight,omitempty"`
+	BlkioWeightDevice    []*WeightDevice   `json:"blkioWeightDevice,omitempty"`
+	BlkioDeviceReadBps   []*ThrottleDevice `json:"blkioDeviceReadBps,omitempty"`
+	BlkioDeviceWriteBps  []*ThrottleDevice `json:"blkioDeviceWriteBps,omitempty"`
+	BlkioDeviceReadIOps  []*ThrottleDevice `json:"blkioDeviceReadIOps,omitempty"`
+	BlkioDeviceWriteIOps []*ThrottleDevice `json:"blkioDeviceWriteIOps,omitempty"`
+	HugetlbLimit         []*HugepageLimit  `json:"hugetlbLimit,omitempty"`
+	//the host devices added to the container besides the DefaultAllowedDevices
+	Devices []*Device `json:"devices,omitempty"`
+}
+
+// Validate check the values of the resource config before they are written to the cgroup
+func (r *ResourceConfig) Validate() error {
+	if r.MemoryLimit < -1 || r.MemoryLimit > 0 && r.MemoryLimit < MinMemoryLimit {
+		return fmt.Errorf("invalid memory limit %d,the minimum is 6MB", r.MemoryLimit)
+	}
+	if r.MemorySwap != 0 {
+		if r.MemoryLimit == 0 || r.MemoryLimit == -1 && r.MemorySwap != -1 {
+			return fmt.Errorf("memory swap can only be set with the memory limit")
+		}
+		if r.MemorySwap < -1 || r.MemorySwap > 0 && r.MemorySwap < r.MemoryLimit {
+			return fmt.Errorf("memory swap %d should be larger than the memory limit %d,it is the total of memory and swap", r.MemorySwap, r.MemoryLimit)
+		}
+	}
+	if r.MemoryReservation < 0 {
+		return fmt.Errorf("invalid memory reservation %d", r.MemoryReservation)
+	}
+	if r.MemoryReservation > 0 && r.MemoryLimit > 0 && r.MemoryReservation > r.MemoryLimit {
+		return fmt.Errorf("memory reservation %d should be smaller than the memory limit %d", r.MemoryReservation, r.MemoryLimit)
+	}
+	if r.MemoryHigh < -1 || r.MemoryHigh > 0 && r.MemoryHigh < MinMemoryLimit {
+		return fmt.Errorf("invalid memory high %d,the minimum is 6MB", r.MemoryHigh)
+	}
+	if r.MemoryHigh > 0 && r.MemoryLimit > 0 && r.MemoryHigh > r.MemoryLimit {
+		return fmt.Errorf("memory high %d should be smaller than the memory limit %d", r.MemoryHigh, r.MemoryLimit)
+	}
+	if r.MemorySwappiness != nil && (*r.MemorySwappiness < 0 || *r.MemorySwappiness > 100) {
+		return fmt.Errorf("invalid memory swappiness %d,the range is from 0 to 100", *r.MemorySwappiness)
+	}
+	if r.CpuShare != "" {
+		if shares, err := strconv.ParseUint(r.CpuShare, 10, 64); err != nil || shares < 2 {
+			return fmt.Errorf("invalid cpu share %s,the minimum is 2", r.CpuShare)
+		}
+	}
+	if _, err := ParseCPUList(r.CpuSet); err != nil {
+		return fmt.Errorf("invalid cpuset %s", r.CpuSet)
+	}
+	if _, err := ParseCPUList(r.CpuSetMems); err != nil {
+		return fmt.Errorf("invalid cpuset mems %s", r.CpuSetMems)
+	}
+	if r.CpuPeriod != 0 && (r.CpuPeriod < 1000 || r.CpuPeriod > 1000000) {
+		return fmt.Errorf("invalid cpu period %d,the range is from 1000 to 1000000", r.CpuPeriod)
+	}
+	if r.CpuQuota > 0 {
+		if r.CpuQuota < 1000 {
+			return fmt.Errorf("invalid cpu quota %d,the minimum is 1000", r.CpuQuota)
+		}
+		period := r.CpuPeriod
+		if period == 0 {
+			period = DefaultCpuPeriod
+		}
+		if cpus := float64(r.CpuQuota) / float64(period); cpus > float64(runtime.NumCPU()) {
+			return fmt.Errorf("cpu quota %d of period %d ask %.2f cpus,only %d cpus are available", r.CpuQuota, period, cpus, runtime.NumCPU())
+		}
+	} else if r.CpuQuota < -1 {
+		return fmt.Errorf("invalid cpu quota %d,use -1 for unlimited", r.CpuQuota)
+	}
+	if r.PidsLimit < -1 {
+		return fmt.Errorf("invalid pids limit %d,use -1 for unlimited", r.PidsLimit)
+	}
+	return nil
+}
+
+// Requires report whether the config set any limit of the subsystem,
+// the cgroup can not be set up when a required subsystem is not available
+func (r *ResourceConfig) Requires(subsystem string) bool {
+	switch subsystem {
+	case "memory":
+		return r.MemoryLimit != 0 || r.MemorySwap != 0 || r.MemoryReservation != 0 || r.MemoryHigh != 0 ||
+			r.MemorySwappiness != nil
+	case "cpu":
+		return r.CpuShare != "" || r.CpuPeriod != 0 || r.CpuQuota != 0
+	case "cpuset":
+		return r.CpuSet != "" || r.CpuSetMems != ""
+	case "pids":
+		return r.PidsLimit != 0
+	case "blkio", "io":
+		return r.BlkioWeight != 0 || len(r.BlkioWeightDevice) > 0 ||
+			len(r.BlkioDeviceReadBps) > 0 || len(r.BlkioDeviceWriteBps) > 0 ||
+			len(r.BlkioDeviceReadIOps) > 0 || len(r.BlkioDeviceWriteIOps) > 0
+	case "hugetlb":
+		return len(r.HugetlbLimit) > 0
+	case "devices":
+		return len(r.Devices) > 0
+	}
+	return false
 }
 
 type SubSystem interface {
@@ -31,24 +139,51 @@ type SubSystem interface {
 	Remove(path string) error
 }
 
+// StatsSubSystem is the subsystem which can report the usage of the cgroup
+type StatsSubSystem interface {
+	GetStats(path string, stats *Stats) error
+}
+
 var (
 	SubSystemIns = []SubSystem{
 		&CpusetSubSystem{},
 		&MemorySubSystem{},
 		&CpuSubSystem{},
+		&CpuacctSubSystem{},
+		&PidsSubSystem{},
+		&BlkioSubSystem{},
+		&FreezerSubSystem{},
+		&HugetlbSubSystem{},
+		&DevicesSubSystem{},
 	}
 )
 
 type MemorySubSystem struct {
 }
 
+// MinMemoryLimit is the minimum memory limit of a container
+const MinMemoryLimit = 6 * MiB
+
 // Set the memory resouce limit
 func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	// get the path of the subsystem resource
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if res.MemoryHigh != 0 {
+			return fmt.Errorf("memory high is not supported by cgroup v1")
+		}
 		//set the cgroup resource limit
-		if err := os.WriteFile(path.Join(subsysCgroupPath, "memory.limit_in_bytes"), []byte(res.MemoryLimit), 0644); err != nil {
-			return fmt.Errorf("set cgroup memory fail %v", err)
+		if err := setMemoryAndSwap(subsysCgroupPath, res); err != nil {
+			return err
+		}
+		if res.MemoryReservation != 0 {
+			if err := writeCgroupFile(subsysCgroupPath, "memory.soft_limit_in_bytes", strconv.FormatInt(res.MemoryReservation, 10)); err != nil {
+				return fmt.Errorf("set cgroup memory reservation fail %v", err)
+			}
+		}
+		if res.MemorySwappiness != nil {
+			if err := writeCgroupFile(subsysCgroupPath, "memory.swappiness", strconv.FormatInt(*res.MemorySwappiness, 10)); err != nil {
+				return fmt.Errorf("set cgroup memory swappiness fail %v", err)
+			}
 		}
 		return nil
 	} else {
@@ -57,10 +192,47 @@ func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 
 }
 
+// the kernel always require memory.limit_in_bytes <= memory.memsw.limit_in_bytes,
+// so the memsw is written first when the new one is not smaller than the current memory limit
+func setMemoryAndSwap(subsysCgroupPath string, res *ResourceConfig) error {
+	setLimit := func() error {
+		if res.MemoryLimit == 0 {
+			return nil
+		}
+		if err := writeCgroupFile(subsysCgroupPath, "memory.limit_in_bytes", strconv.FormatInt(res.MemoryLimit, 10)); err != nil {
+			return fmt.Errorf("set cgroup memory fail %v", err)
+		}
+		return nil
+	}
+	if res.MemorySwap == 0 {
+		return setLimit()
+	}
+	setSwap := func() error {
+		if err := writeCgroupFile(subsysCgroupPath, "memory.memsw.limit_in_bytes", strconv.FormatInt(res.MemorySwap, 10)); err != nil {
+			return fmt.Errorf("set cgroup memory swap fail %v,is the swap account enabled? %v", res.MemorySwap, err)
+		}
+		return nil
+	}
+	currentLimit, err := readCgroupUint(subsysCgroupPath, "memory.limit_in_bytes")
+	if err != nil {
+		return err
+	}
+	if res.MemorySwap == -1 || uint64(res.MemorySwap) >= currentLimit {
+		if err := setSwap(); err != nil {
+			return err
+		}
+		return setLimit()
+	}
+	if err := setLimit(); err != nil {
+		return err
+	}
+	return setSwap()
+}
+
 // remove the cgroupPath to the cgroup
 func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		return os.Remove(subsysCgroupPath)
+		return FS.Remove(subsysCgroupPath)
 	} else {
 		return err
 	}
@@ -70,7 +242,7 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
 		//write the pid to the "task" file
-		if err := os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := FS.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -79,14 +251,31 @@ func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 	}
 }
 
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Memory.Usage, err = readCgroupUint(subsysCgroupPath, "memory.usage_in_bytes"); err != nil {
+		return err
+	}
+	if stats.Memory.Limit, err = readCgroupUint(subsysCgroupPath, "memory.limit_in_bytes"); err != nil {
+		return err
+	}
+	if stats.Memory.MaxUsage, err = readCgroupUint(subsysCgroupPath, "memory.max_usage_in_bytes"); err != nil {
+		return err
+	}
+	return nil
+}
+
 // return the cgroup name
 func (s *MemorySubSystem) Name() string {
 	return "memory"
 }
 
-// get the cgroup by the "/proc/self/mountinfo"
+// get the cgroup by the mountinfo,it is "/proc/self/mountinfo" unless OpenMountInfo is replaced
 func FindCgroupMountpoint(subsystem string) string {
-	f, err := os.Open("/proc/self/mountinfo")
+	f, err := OpenMountInfo()
 	if err != nil {
 		return ""
 	}
@@ -98,7 +287,7 @@ func FindCgroupMountpoint(subsystem string) string {
 		fields := strings.Split(txt, " ")
 		for _, opt := range strings.Split(fields[len(fields)-1], ",") {
 			if opt == subsystem {
-				return fields[4]
+				return rootedMountpoint(fields[4])
 			}
 		}
 	}
@@ -108,185 +297,20 @@ func FindCgroupMountpoint(subsystem string) string {
 	return ""
 }
 
-// get the absolute path
+// get the absolute path,the cgroup is created when autoCreate is true
 func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
 	cgroupRoot := FindCgroupMountpoint(subsystem)
-	if _, err := os.Stat(path.Join(cgroupRoot, cgroupPath)); err == nil || (autoCreate && os.IsNotExist(err)) {
-		if os.IsNotExist(err) {
-			if err := os.Mkdir(path.Join(cgroupRoot, cgroupPath), 0755); err == nil {
-			} else {
-				return "", fmt.Errorf("error create cgroup %v", err)
-			}
-			return path.Join(cgroupRoot, cgroupPath), nil
-		} else {
-			return "", fmt.Errorf("cgroup path error %v", err)
-		}
-	}
-	return "", nil
-}
-
-func pivotRoot(root string) error {
-	// for the new root,remount the new root
-	if err := syscall.Mount(root, root, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
-		return fmt.Errorf("Mount rootfs to itself error: %v", err)
-	}
-	// create rootfs/.pivot_root storage the old_root
-	pivotDir := filepath.Join(root, ".pivot_root")
-	if err := os.Mkdir(pivotDir, 0777); err != nil {
-		return err
-	}
-	//pivot_root to the new rootfs
-	if err := syscall.PivotRoot(root, pivotDir); err != nil {
-		return fmt.Errorf("pivot_root %v", err)
-	}
-	//edit the new work dir to the root
-	if err := syscall.Chdir("/"); err != nil {
-		return fmt.Errorf("chdir / %v", err)
-	}
-
-	pivotDir = filepath.Join("/", ".pivot_root")
-	//umount rootfs/ .pivot_root
-	if err := syscall.Unmount(pivotDir, syscall.MNT_DETACH); err != nil {
-		return fmt.Errorf("umount pivot_root dir %v", err)
-	}
-	//remove the temp file
-	return os.Remove(pivotDir)
-}
-
-func setUpMount() {
-	pwd, err := os.Getwd()
-	if err != nil {
-		log.Errorf("Get current location error")
-		return
-	}
-	log.Infof("Current location is %s", pwd)
-	pivotRoot(pwd)
-
-	//mount proc
-	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
-	syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=75")
-}
-
-func NewWorkSpace(volume, imageName, containerName string) {
-	CreateReadOnlyLayer(container.RootUrl)
-	CreateWriteLayer(container.RootUrl)
-	CreateMountPoint(container.RootUrl, container.MntUrl)
-	CreateReadOnlyLayer(imageName)
-	CreateWriteLayer(containerName)
-	CreateMountPoint(containerName, imageName)
-	if volume != "" {
-		//analytic the volume
-		volumeURLS := volumeUrlExtract(volume)
-		length := len(volumeURLS)
-		if length == 2 && volumeURLS[0] != "" && volumeURLS[1] != "" {
-			//mount the data volume
-			MountVolume(container.RootUrl, container.MntUrl, volumeURLS)
-			log.Infof("%q", volumeURLS)
-		} else {
-			log.Infof("Volume parameter input is not correct.")
-		}
+	if cgroupRoot == "" {
+		return "", fmt.Errorf("cgroup subsystem %s is not mounted", subsystem)
 	}
-	MountVolume(container.RootUrl, container.MntUrl, volume)
-}
-
-func MountVolume(rootURL string, mntURL string, volumeURLs []string) {
-	//create host flooder
-	parentUrl := volumeURLs[0]
-	if err := os.Mkdir(parentUrl, 0777); err != nil {
-		log.Infof("Mkdir parent dir %s error. %v", parentUrl, err)
-	}
-	//mount the point into the volume flooder
-	containeerUrl := volumeURLs[1]
-	containeerVolumeURL := mntURL + containeerUrl
-	if err := os.Mkdir(containeerVolumeURL, 0777); err != nil {
-		log.Infof("Mkdir container dir %s error. %v", containeerUrl, err)
-	}
-	//put the host mount the volume
-	dirs := "dirs=" + parentUrl
-	cmd := exec.Command("mount", "-t", "aufs", "-o", dirs, "none", containeerVolumeURL)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Mount volume failed %v.", err)
-	}
-}
-
-// analytic the volume's string
-func volumeUrlExtract(volume string) []string {
-	var volumeURLs []string
-	volumeURLs = strings.Split(volume, ":")
-	return volumeURLs
-}
-
-// to busybox.tar unzip to the file busybox,and the container as the readonly layer
-func CreateReadOnlyLayer(rootURL string) {
-	busyboxURL := rootURL + "busybox/"
-	busyboxTarURL := rootURL + "busybox.tar"
-	exist, err := PathExists(busyboxURL)
-	if err != nil {
-		log.Infof("Fail to judge whether dir %s exist. %v", busyboxURL, err)
-	}
-	if !exist {
-		if err := os.Mkdir(busyboxTarURL, 0777); err != nil {
-			log.Infof("Mkdir dir %s error. %v", busyboxURL, err)
+	absPath := path.Join(cgroupRoot, cgroupPath)
+	if _, err := FS.Stat(absPath); err != nil {
+		if !os.IsNotExist(err) || !autoCreate {
+			return "", fmt.Errorf("cgroup path error %w", err)
 		}
-		if _, err := exec.Command("tar", "-xvf", busyboxTarURL, "-C", busyboxURL).CombinedOutput(); err != nil {
-			log.Errorf("unTar dir %s error %v", busyboxTarURL, err)
+		if err := FS.MkdirAll(absPath, 0755); err != nil {
+			return "", fmt.Errorf("error create cgroup %v", err)
 		}
 	}
-}
-
-// create the writelayer as the container's only layer
-func CreateWriteLayer(rootURL string) {
-	writeURL := rootURL + "writeLayer/"
-	if err := os.Mkdir(writeURL, 0777); err != nil {
-		log.Errorf("Mkdir dir %s error. %v", writeURL, err)
-	}
-}
-
-func CreateMountPoint(rootURL string, mntURL string) {
-	//create the file mnt as the mount point
-	if err := os.Mkdir(mntURL, 0777); err != nil {
-		log.Error("Mkdir dir %s error. %v", mntURL, err)
-	}
-	//put the writeLayer and busybo file and the mount to the mnt
-	dirs := "dirs=" + rootURL + "writeLayer:" + rootURL + "busybox"
-	cmd := exec.Command("mount", "-t", "aufs", "-o", dirs, "none", mntURL)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Errorf("%v", err)
-	}
-}
-
-// judge the path is exists or not
-func PathExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err != nil {
-		return true, nil
-	}
-	if os.IsNotExist(err) {
-		return false, nil
-	}
-	return false, err
-}
-
-func DeleteMountPoint(rootURL string, mntURL string) {
-	cmd := exec.Command("umount", mntURL)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Errorf("%v", err)
-	}
-	if err := os.RemoveAll(mntURL); err != nil {
-		log.Errorf("Remove dir %s error %v", mntURL, err)
-	}
-}
-
-func DeleteWriteLayer(rootURL string) {
-	writeURL := rootURL + "writeLayer/"
-	if err := os.RemoveAll(writeURL); err != nil {
-		log.Errorf("Remove dir %s error %v", writeURL, err)
-	}
+	return absPath, nil
 }
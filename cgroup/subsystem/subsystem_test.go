@@ -0,0 +1,59 @@
+package subsystem
+
+import "testing"
+
+func TestResourceConfigValidate(t *testing.T) {
+	swappiness := func(v int64) *int64 { return &v }
+	valid := []ResourceConfig{
+		{},
+		{MemoryLimit: -1},
+		{MemoryLimit: MinMemoryLimit},
+		{MemoryLimit: 100 * MiB, MemorySwap: 200 * MiB},
+		{MemoryLimit: 100 * MiB, MemorySwap: 100 * MiB},
+		{MemoryLimit: 100 * MiB, MemorySwap: -1},
+		{MemoryLimit: -1, MemorySwap: -1},
+		{MemoryLimit: 100 * MiB, MemoryReservation: 50 * MiB},
+		{MemoryReservation: 50 * MiB},
+		{MemoryLimit: 100 * MiB, MemoryHigh: 80 * MiB},
+		{MemoryHigh: -1},
+		{MemorySwappiness: swappiness(0)},
+		{MemorySwappiness: swappiness(100)},
+		{CpuShare: "2"},
+		{CpuSet: "0", CpuSetMems: "0"},
+		{CpuPeriod: 100000, CpuQuota: 50000},
+		{CpuQuota: -1},
+		{PidsLimit: -1},
+	}
+	for _, res := range valid {
+		if err := res.Validate(); err != nil {
+			t.Errorf("%+v: %v", res, err)
+		}
+	}
+	invalid := []ResourceConfig{
+		{MemoryLimit: -2},
+		{MemoryLimit: MinMemoryLimit - 1},
+		{MemorySwap: 200 * MiB},
+		{MemoryLimit: -1, MemorySwap: 200 * MiB},
+		{MemoryLimit: 100 * MiB, MemorySwap: 50 * MiB},
+		{MemoryLimit: 100 * MiB, MemorySwap: -2},
+		{MemoryReservation: -1},
+		{MemoryLimit: 100 * MiB, MemoryReservation: 200 * MiB},
+		{MemoryHigh: 1024},
+		{MemoryLimit: 100 * MiB, MemoryHigh: 200 * MiB},
+		{MemorySwappiness: swappiness(-1)},
+		{MemorySwappiness: swappiness(101)},
+		{CpuShare: "1"},
+		{CpuShare: "abc"},
+		{CpuSet: "0-"},
+		{CpuSetMems: "a"},
+		{CpuPeriod: 999},
+		{CpuQuota: 999},
+		{CpuQuota: -2},
+		{PidsLimit: -2},
+	}
+	for _, res := range invalid {
+		if err := res.Validate(); err == nil {
+			t.Errorf("%+v: want error", res)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package subsystem
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	KiB int64 = 1024
+	MiB       = 1024 * KiB
+	GiB       = 1024 * MiB
+	TiB       = 1024 * GiB
+)
+
+// the size is a number with an optional unit,like 512b,512k,100m,1.5g,2GiB,
+// the "i" is only allowed after the unit prefix
+var sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(?:([kmgt])(?:i?b)?|b)?$`)
+
+var unitBytes = map[string]int64{
+	"":  1,
+	"k": KiB,
+	"m": MiB,
+	"g": GiB,
+	"t": TiB,
+}
+
+// RAMInBytes parse the human readable size into bytes,the units are binary
+func RAMInBytes(size string) (int64, error) {
+	matches := sizeRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(size)))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %s,use a number with an optional unit b,k,m,g or t", size)
+	}
+	unit := unitBytes[matches[2]]
+	//an integer is parsed exactly,so the sizes up to the max int64 are kept
+	if !strings.Contains(matches[1], ".") {
+		value, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil || value > math.MaxInt64/unit {
+			return 0, fmt.Errorf("size %s is too large", size)
+		}
+		return value * unit, nil
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %s %v", size, err)
+	}
+	bytes := value * float64(unit)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %s is too large", size)
+	}
+	return int64(bytes), nil
+}
+
+// ParseMemoryLimit is like RAMInBytes,but also accept -1 as unlimited
+func ParseMemoryLimit(size string) (int64, error) {
+	if strings.TrimSpace(size) == "-1" {
+		return -1, nil
+	}
+	return RAMInBytes(size)
+}
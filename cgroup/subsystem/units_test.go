@@ -0,0 +1,67 @@
+package subsystem
+
+import "testing"
+
+func TestRAMInBytes(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{"512b", 512},
+		{"512k", 512 * KiB},
+		{"512K", 512 * KiB},
+		{"100m", 100 * MiB},
+		{"100MB", 100 * MiB},
+		{"1.5g", 3 * GiB / 2},
+		{"2GiB", 2 * GiB},
+		{" 1t ", TiB},
+		{"6 m", 6 * MiB},
+		{"5kib", 5 * KiB},
+		{"9223372036854775807", 9223372036854775807},
+		{"8388607t", 8388607 * TiB},
+	}
+	for _, test := range tests {
+		got, err := RAMInBytes(test.size)
+		if err != nil {
+			t.Errorf("%q: %v", test.size, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q = %d,want %d", test.size, got, test.want)
+		}
+	}
+	for _, size := range []string{"", "-1", "m", "1.5.2m", "10x", "1pb", "10 mega",
+		"5ib", "5i", "5bb", "9999999999t", "8388608t", "9223372036854775808", "99999999999999999999", "8388608.5t"} {
+		if _, err := RAMInBytes(size); err == nil {
+			t.Errorf("%q: want error", size)
+		}
+	}
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+	}{
+		{"-1", -1},
+		{" -1 ", -1},
+		{"100m", 100 * MiB},
+	}
+	for _, test := range tests {
+		got, err := ParseMemoryLimit(test.size)
+		if err != nil {
+			t.Errorf("%q: %v", test.size, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q = %d,want %d", test.size, got, test.want)
+		}
+	}
+	for _, size := range []string{"-2", "-1m", "unlimited"} {
+		if _, err := ParseMemoryLimit(size); err == nil {
+			t.Errorf("%q: want error", size)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package container
+
+import (
+	"docker-my/cgroup/subsystem"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ENV_CGROUPNS tell the init process whether to unshare the cgroup namespace
+	ENV_CGROUPNS = "mydocker_cgroupns"
+
+	CgroupnsPrivate = "private"
+	CgroupnsHost    = "host"
+)
+
+// the mountpoint of the cgroup file system in the rootfs of the container
+const containerCgroupMountpoint = "sys/fs/cgroup"
+
+// ParseCgroupns check the value of --cgroupns
+func ParseCgroupns(mode string) (string, error) {
+	switch mode {
+	case "", CgroupnsPrivate:
+		return CgroupnsPrivate, nil
+	case CgroupnsHost:
+		return CgroupnsHost, nil
+	}
+	return "", fmt.Errorf("invalid cgroupns mode %s,it should be %s or %s", mode, CgroupnsPrivate, CgroupnsHost)
+}
+
+// cgroupfsMount is one mount of the cgroup file systems in the rootfs
+type cgroupfsMount struct {
+	Source string
+	Target string
+	FsType string
+	Flags  uintptr
+	Data   string
+}
+
+// cgroupfsLink is the symlink of a co-mounted controller,such as cpu -> cpu,cpuacct
+type cgroupfsLink struct {
+	Link   string
+	Target string
+}
+
+// setUpCgroupNamespace unshare the cgroup namespace and mount the cgroup file system
+// read only in the rootfs,it must be called after the parent put the process into the cgroup
+// of the container,so the root of the namespace is the cgroup of the container
+func setUpCgroupNamespace(rootfs string) error {
+	if err := unix.Unshare(unix.CLONE_NEWCGROUP); err != nil {
+		return fmt.Errorf("unshare cgroup namespace error %v", err)
+	}
+	hostMounts, err := subsystem.CgroupMounts()
+	if err != nil {
+		return fmt.Errorf("read cgroup mounts error %v", err)
+	}
+	target := filepath.Join(rootfs, containerCgroupMountpoint)
+	unified := subsystem.IsCgroup2UnifiedMode()
+	mounts, links := planCgroupfs(target, unified, hostMounts)
+	for _, mount := range mounts {
+		if err := os.MkdirAll(mount.Target, 0755); err != nil {
+			return fmt.Errorf("mkdir %s error %v", mount.Target, err)
+		}
+		if err := unix.Mount(mount.Source, mount.Target, mount.FsType, mount.Flags, mount.Data); err != nil {
+			return fmt.Errorf("mount %s to %s error %v", mount.FsType, mount.Target, err)
+		}
+	}
+	for _, link := range links {
+		if err := os.Symlink(link.Target, link.Link); err != nil && !os.IsExist(err) {
+			logrus.Warnf("link %s error %v", link.Link, err)
+		}
+	}
+	if unified {
+		return nil
+	}
+	//the tmpfs holding the v1 hierarchies is made read only at last
+	if err := unix.Mount("", target, "", uintptr(unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOEXEC|unix.MS_NOSUID|unix.MS_NODEV), ""); err != nil {
+		return fmt.Errorf("remount %s read only error %v", target, err)
+	}
+	return nil
+}
+
+// planCgroupfs return the mounts in order and the symlinks which put the cgroup file systems
+// of the host under the target with the same layout,every cgroup file system is read only
+func planCgroupfs(target string, unified bool, hostMounts []subsystem.CgroupMount) ([]cgroupfsMount, []cgroupfsLink) {
+	flags := uintptr(unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_RDONLY)
+	if unified {
+		return []cgroupfsMount{{Source: "cgroup2", Target: target, FsType: "cgroup2", Flags: flags}}, nil
+	}
+	//v1 hierarchies are mounted under a tmpfs just like the host
+	mounts := []cgroupfsMount{{
+		Source: "tmpfs",
+		Target: target,
+		FsType: "tmpfs",
+		Flags:  uintptr(unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV),
+		Data:   "mode=755",
+	}}
+	var links []cgroupfsLink
+	for _, hostMount := range hostMounts {
+		dir := filepath.Join(target, filepath.Base(hostMount.Mountpoint))
+		if hostMount.FsType == "cgroup2" {
+			mounts = append(mounts, cgroupfsMount{Source: "cgroup2", Target: dir, FsType: "cgroup2", Flags: flags})
+			continue
+		}
+		mounts = append(mounts, cgroupfsMount{
+			Source: "cgroup",
+			Target: dir,
+			FsType: "cgroup",
+			Flags:  flags,
+			Data:   strings.Join(hostMount.Controllers, ","),
+		})
+		//link the co-mounted controllers,such as cpu -> cpu,cpuacct
+		if len(hostMount.Controllers) > 1 {
+			for _, controller := range hostMount.Controllers {
+				if strings.HasPrefix(controller, "name=") {
+					continue
+				}
+				links = append(links, cgroupfsLink{
+					Link:   filepath.Join(target, controller),
+					Target: filepath.Base(hostMount.Mountpoint),
+				})
+			}
+		}
+	}
+	return mounts, links
+}
@@ -0,0 +1,76 @@
+package container
+
+import (
+	"docker-my/cgroup/subsystem"
+	"golang.org/x/sys/unix"
+	"reflect"
+	"testing"
+)
+
+func TestParseCgroupns(t *testing.T) {
+	for mode, want := range map[string]string{"": CgroupnsPrivate, "private": CgroupnsPrivate, "host": CgroupnsHost} {
+		got, err := ParseCgroupns(mode)
+		if err != nil || got != want {
+			t.Errorf("%q = %q,%v,want %q", mode, got, err, want)
+		}
+	}
+	if _, err := ParseCgroupns("shared"); err == nil {
+		t.Error("shared: want error")
+	}
+}
+
+func TestPlanCgroupfsUnified(t *testing.T) {
+	mounts, links := planCgroupfs("/rootfs/sys/fs/cgroup", true, []subsystem.CgroupMount{
+		{Mountpoint: "/sys/fs/cgroup", FsType: "cgroup2"},
+	})
+	want := []cgroupfsMount{{
+		Source: "cgroup2",
+		Target: "/rootfs/sys/fs/cgroup",
+		FsType: "cgroup2",
+		Flags:  unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_RDONLY,
+	}}
+	if !reflect.DeepEqual(mounts, want) || len(links) != 0 {
+		t.Errorf("mounts %+v,links %+v", mounts, links)
+	}
+}
+
+func TestPlanCgroupfsV1(t *testing.T) {
+	target := "/rootfs/sys/fs/cgroup"
+	mounts, links := planCgroupfs(target, false, []subsystem.CgroupMount{
+		{Mountpoint: "/sys/fs/cgroup/systemd", FsType: "cgroup", Controllers: []string{"name=systemd"}},
+		{Mountpoint: "/sys/fs/cgroup/memory", FsType: "cgroup", Controllers: []string{"memory"}},
+		{Mountpoint: "/sys/fs/cgroup/cpu,cpuacct", FsType: "cgroup", Controllers: []string{"cpu", "cpuacct"}},
+		{Mountpoint: "/sys/fs/cgroup/net_cls,net_prio,name=foo", FsType: "cgroup", Controllers: []string{"net_cls", "net_prio", "name=foo"}},
+		//the hybrid host has the unified hierarchy besides the v1 ones
+		{Mountpoint: "/sys/fs/cgroup/unified", FsType: "cgroup2"},
+	})
+	if len(mounts) != 6 {
+		t.Fatalf("mounts %+v", mounts)
+	}
+	//the tmpfs come first and stay writable until the hierarchies are mounted under it
+	if mounts[0].FsType != "tmpfs" || mounts[0].Target != target || mounts[0].Flags&unix.MS_RDONLY != 0 {
+		t.Errorf("first mount %+v,want the writable tmpfs on the target", mounts[0])
+	}
+	wantTargets := []string{"systemd", "memory", "cpu,cpuacct", "net_cls,net_prio,name=foo", "unified"}
+	wantData := []string{"name=systemd", "memory", "cpu,cpuacct", "net_cls,net_prio,name=foo", ""}
+	for i, mount := range mounts[1:] {
+		if mount.Target != target+"/"+wantTargets[i] || mount.Data != wantData[i] {
+			t.Errorf("mount %d %+v,want %s with %q", i+1, mount, wantTargets[i], wantData[i])
+		}
+		if mount.Flags&unix.MS_RDONLY == 0 {
+			t.Errorf("mount %s is not read only", mount.Target)
+		}
+	}
+	if mounts[5].FsType != "cgroup2" {
+		t.Errorf("unified mount %+v", mounts[5])
+	}
+	wantLinks := []cgroupfsLink{
+		{Link: target + "/cpu", Target: "cpu,cpuacct"},
+		{Link: target + "/cpuacct", Target: "cpu,cpuacct"},
+		{Link: target + "/net_cls", Target: "net_cls,net_prio,name=foo"},
+		{Link: target + "/net_prio", Target: "net_cls,net_prio,name=foo"},
+	}
+	if !reflect.DeepEqual(links, wantLinks) {
+		t.Errorf("links %+v,want %+v", links, wantLinks)
+	}
+}
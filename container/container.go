@@ -1,26 +1,88 @@
 package container
 
 import (
+	"bytes"
 	"docker-my/cgroup/subsystem"
+	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"syscall"
 )
 
 // RunContainerInitProcess mount the container to the proc
 func RunContainerInitProcess(command string, args []string) error {
+	//block until the parent apply the cgroup and send the command through the pipe
+	if cmdArray := readUserCommand(); len(cmdArray) > 0 {
+		command, args = cmdArray[0], cmdArray[1:]
+	}
 	logrus.Infof("command %s", command)
+	//keep the mounts of the container from propagating to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("make the mount namespace private error %v", err)
+	}
+	if os.Getenv(ENV_CGROUPNS) != CgroupnsHost {
+		rootfs, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get rootfs error %v", err)
+		}
+		if err := setUpCgroupNamespace(rootfs); err != nil {
+			return err
+		}
+	}
 	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
 	syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	argv := []string{command}
-	if err := syscall.Exec(command, argv, os.Environ()); err != nil {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return fmt.Errorf("look path %s error %v", command, err)
+	}
+	argv := append([]string{command}, args...)
+	if err := syscall.Exec(path, argv, os.Environ()); err != nil {
 		logrus.Errorf(err.Error())
 	}
 	return nil
 }
 
+// read the command from the pipe passed as the fd 3
+func readUserCommand() []string {
+	pipe := os.NewFile(uintptr(3), "pipe")
+	defer pipe.Close()
+	msg, err := ioutil.ReadAll(pipe)
+	if err != nil {
+		logrus.Errorf("init read pipe error %v", err)
+		return nil
+	}
+	argv, err := parseInitCommand(msg)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return nil
+	}
+	return argv
+}
+
+// WriteInitCommand send the argv of the user command to the init process,it is encoded
+// as json so the arguments with the spaces or the quotes reach the init as they are
+func WriteInitCommand(w io.Writer, argv []string) error {
+	return json.NewEncoder(w).Encode(argv)
+}
+
+// parseInitCommand decode what WriteInitCommand send,nothing is sent when the parent fail
+func parseInitCommand(msg []byte) ([]string, error) {
+	if len(bytes.TrimSpace(msg)) == 0 {
+		return nil, nil
+	}
+	var argv []string
+	if err := json.Unmarshal(msg, &argv); err != nil {
+		return nil, fmt.Errorf("decode init command error %v", err)
+	}
+	return argv, nil
+}
+
 func NewPipe() (*os.File, *os.File, error) {
 	read, write, err := os.Pipe()
 	if err != nil {
@@ -29,7 +91,18 @@ func NewPipe() (*os.File, *os.File, error) {
 	return read, write, nil
 }
 
-func NewParentProcess(tty bool, containerName, volume, imageName string) (*exec.Cmd, *os.File) {
+func NewParentProcess(tty bool, containerName, volume, imageName, cgroupns string) (*exec.Cmd, *os.File) {
+	cmd, writePipe := NewInitProcess(tty, containerName, cgroupns)
+	if cmd == nil {
+		return nil, nil
+	}
+	NewWorkSpace(volume, imageName, containerName)
+	return cmd, writePipe
+}
+
+// NewInitProcess create the init process in the rootfs of the container without setting up
+// the workspace,it is also used to restart the container in its existing rootfs
+func NewInitProcess(tty bool, containerName, cgroupns string) (*exec.Cmd, *os.File) {
 	readPipe, writePipe, err := NewPipe()
 	if err != nil {
 		logrus.Errorf("New pipe error %v", err)
@@ -51,19 +124,51 @@ func NewParentProcess(tty bool, containerName, volume, imageName string) (*exec.
 			return nil, nil
 		}
 		stdLogFilePath := dirURL + ContainerLogFile
-		stdLogFile, err := os.Create(stdLogFilePath)
+		//a restarted container keep appending to the same log
+		stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			logrus.Errorf("NewParentProcess create file %s error %v", stdLogFilePath, err)
 			return nil, nil
 		}
+		//the logs of the init process are on stderr,they are kept in the log with the output
 		cmd.Stdout = stdLogFile
+		cmd.Stderr = stdLogFile
 	}
 	cmd.ExtraFiles = []*os.File{readPipe}
-	subsystem.NewWorkSpace(volume, imageName, containerName)
+	//the cgroup namespace is unshared by the init process after it is put into the cgroup
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", ENV_CGROUPNS, cgroupns))
 	cmd.Dir = fmt.Sprintf(MntUrl, containerName)
 	return cmd, writePipe
 }
 
+// CreateDeviceNodes create the nodes of the devices added by --device in the rootfs of the container
+func CreateDeviceNodes(containerName string, devices []*subsystem.Device) error {
+	rootfs := fmt.Sprintf(MntUrl, containerName)
+	for _, device := range devices {
+		nodePath := filepath.Join(rootfs, device.Path)
+		if err := os.MkdirAll(filepath.Dir(nodePath), 0755); err != nil {
+			return fmt.Errorf("mkdir for device %s error %v", nodePath, err)
+		}
+		mode := uint32(device.FileMode)
+		if device.Type == "b" {
+			mode |= syscall.S_IFBLK
+		} else {
+			mode |= syscall.S_IFCHR
+		}
+		if err := os.Remove(nodePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove old device %s error %v", nodePath, err)
+		}
+		dev := unix.Mkdev(uint32(device.Major), uint32(device.Minor))
+		if err := syscall.Mknod(nodePath, mode, int(dev)); err != nil {
+			return fmt.Errorf("mknod device %s error %v", nodePath, err)
+		}
+		if err := os.Chown(nodePath, int(device.Uid), int(device.Gid)); err != nil {
+			return fmt.Errorf("chown device %s error %v", nodePath, err)
+		}
+	}
+	return nil
+}
+
 func DeleteWorkSpace(rootURL string, mntURL string, volume string) {
 	if volume != "" {
 		volumeURLs := volumeUrlExtract(volume)
@@ -71,12 +176,12 @@ func DeleteWorkSpace(rootURL string, mntURL string, volume string) {
 		if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
 			DeleteMountPointWithVolume(rootURL, mntURL, volumeURLs)
 		} else {
-			subsystem.DeleteMountPoint(rootURL, mntURL)
+			DeleteMountPoint(rootURL, mntURL)
 		}
 	} else {
-		subsystem.DeleteMountPoint(rootURL, mntURL)
+		DeleteMountPoint(rootURL, mntURL)
 	}
-	subsystem.DeleteWriteLayer(rootURL)
+	DeleteWriteLayer(rootURL)
 }
 
 func DeleteMountPointWithVolume(rootURL string, mntURL string, volumeURLs []string) {
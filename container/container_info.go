@@ -1,17 +1,15 @@
 package container
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"docker-my/cgroup/subsystem"
+	"encoding/hex"
 	"fmt"
-	log "github.com/sirupsen/logrus"
-	"math/rand"
-	"os"
-	"strconv"
-	"strings"
-	"time"
 )
 
 type ContainerInfo struct {
+	//the schema version of the info,see the state package
+	Version     int    `json:"version"`
 	Pid         string `json:"pid"`
 	Id          string `json:"id"`
 	Name        string `json:"name"`
@@ -19,78 +17,59 @@ type ContainerInfo struct {
 	CreatedTime string `json:"createdTime"`
 	Status      string `json:"status"`
 	Volume      string `json:"volume"`
+	CgroupPath  string `json:"cgroupPath"`
+	//whether the container is killed by the oom killer,and when it is found
+	OOMKilled     bool   `json:"oomKilled"`
+	OOMKilledTime string `json:"oomKilledTime,omitempty"`
+	//the oom kill counter of the cgroup when the container is restarted,the kills before it are not counted
+	OOMKillBase uint64 `json:"oomKillBase,omitempty"`
+	//the resource limit of the container cgroup
+	Resources *subsystem.ResourceConfig `json:"resources,omitempty"`
+	//the cpus allocated by --cpus-exclusive
+	ExclusiveCpus string `json:"exclusiveCpus,omitempty"`
+	//the monitor process which is the parent of the container process and record its exit
+	MonitorPid int `json:"monitorPid,omitempty"`
+	//how the container process finished,the exit code of a process killed by a signal is 128+signal
+	ExitCode     int    `json:"exitCode"`
+	ExitSignal   string `json:"exitSignal,omitempty"`
+	FinishedTime string `json:"finishedTime,omitempty"`
+	//the policy the monitor restart the container by,and how many times it is restarted
+	RestartPolicy RestartPolicy `json:"restartPolicy"`
+	RestartCount  int           `json:"restartCount"`
+	//the container is stopped by the stop command,it is never restarted
+	ManuallyStopped bool `json:"manuallyStopped,omitempty"`
 }
 
 var (
 	RUNNING             string = "running"
+	PAUSED              string = "paused"
+	RESTARTING          string = "restarting"
 	STOP                string = "stoped"
-	EXIT                string = "eixted"
-	DefaultInfoLocation string = "/var/run/mydocker/%s"
+	EXIT                string = "exited"
+	DefaultInfoLocation string = "/var/run/mydocker/%s/"
 	ConfigName          string = "config.json"
+	ContainerLogFile    string = "container.log"
 	RootUrl             string = "/root"
 	MntUrl              string = "/root/mnt/%s"
 	WriteLayerUrl       string = "/root/writeLayer/%s"
 )
 
-// product the container id
-func randStringBytes(n int) string {
-	letterBytes := "1234567890"
-	rand.Seed(time.Now().UnixNano())
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
-	}
-	return string(b)
-}
+// the length of the id shown by ps and stats
+const shortIDLength = 12
 
-func RecordContainerInfo(containerPID int, commandArray []string, containerName, id, volume string) (string, error) {
-	id := randStringBytes(10)
-	createTime := time.Now().Format("2006-01-02 15:04:05")
-	command := strings.Join(commandArray, "")
-	if containerName == "" {
-		containerName = id
-	}
-	//create the new instance
-	containerInfo := &ContainerInfo{
-		Id:          id,
-		Pid:         strconv.Itoa(containerPID),
-		Command:     command,
-		CreatedTime: createTime,
-		Status:      RUNNING,
-		Name:        containerName,
-		Volume:      volume,
-	}
-	jsonBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		log.Errorf("Record container info error %v", err)
-		return "", err
-	}
-	jsonStr := string(jsonBytes)
-	//combine the container path info
-	dirUrl := fmt.Sprintf(DefaultInfoLocation, containerName)
-	//if the path not exist ,combine and create it
-	if err := os.MkdirAll(dirUrl, 0622); err != nil {
-		log.Errorf("Mkdir error %s error %v", dirUrl, err)
-		return "", err
-	}
-	fileName := dirUrl + "/" + ConfigName
-	file, err := os.Create(fileName)
-	defer file.Close()
-	if err != nil {
-		log.Errorf("Create file %s error %v", fileName, err)
-		return "", err
-	}
-	//put the json data to the file
-	if _, err := file.WriteString(jsonStr); err != nil {
-		log.Errorf("File write string error %v", err)
-		return "", err
+// NewContainerID product a random id of 64 hex digits for a new container
+func NewContainerID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate container id error %v", err)
 	}
-	return containerName, nil
+	return hex.EncodeToString(b), nil
 }
 
-func DeleteContainerInfo(containerId string) {
-	dirUrl := fmt.Sprintf(DefaultInfoLocation, containerId)
-	if err := os.RemoveAll(dirUrl); err != nil {
-		log.Errorf("Remove dir %s error %v", dirUrl, err)
+// ShortID return the leading digits of the id,it is also the default name of a container
+func ShortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
 	}
+	return id
 }
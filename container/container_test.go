@@ -0,0 +1,43 @@
+package container
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInitCommandRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{"top"},
+		{"sh", "-c", "echo hello world && sleep 1"},
+		{"printf", "%s\n", "a  b", ""},
+		{"echo", `"quoted"`, "it's", "tab\there", "new\nline"},
+		{"/bin/ls", "-l", "/dir with space"},
+	}
+	for _, argv := range tests {
+		var buf bytes.Buffer
+		if err := WriteInitCommand(&buf, argv); err != nil {
+			t.Fatalf("%q: %v", argv, err)
+		}
+		got, err := parseInitCommand(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%q: %v", argv, err)
+		}
+		if !reflect.DeepEqual(got, argv) {
+			t.Errorf("sent %q,received %q", argv, got)
+		}
+	}
+}
+
+func TestParseInitCommand(t *testing.T) {
+	//the parent close the pipe without sending when the setup fail
+	for _, msg := range []string{"", " \n"} {
+		argv, err := parseInitCommand([]byte(msg))
+		if err != nil || argv != nil {
+			t.Errorf("%q = %q,%v,want nothing", msg, argv, err)
+		}
+	}
+	if _, err := parseInitCommand([]byte("top -b")); err == nil {
+		t.Error("the plain text command should be rejected")
+	}
+}
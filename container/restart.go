@@ -0,0 +1,70 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// RestartPolicy tell the monitor whether to start the container again after it exit,
+// a container stopped by the stop command is never restarted.
+// there is no daemon bringing the containers back after the host reboot,
+// so always and unless-stopped behave the same
+type RestartPolicy struct {
+	Name string `json:"name"`
+	// the max restarts of on-failure,0 means no limit
+	MaximumRetryCount int `json:"maximumRetryCount,omitempty"`
+}
+
+// ParseRestartPolicy check the value of --restart,no|on-failure[:max]|always|unless-stopped
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	name, max, hasMax := strings.Cut(policy, ":")
+	switch name {
+	case "", RestartNo, RestartAlways, RestartUnlessStopped:
+		if hasMax {
+			return RestartPolicy{}, fmt.Errorf("max restarts is only supported by %s", RestartOnFailure)
+		}
+		if name == "" {
+			name = RestartNo
+		}
+		return RestartPolicy{Name: name}, nil
+	case RestartOnFailure:
+		restartPolicy := RestartPolicy{Name: name}
+		if hasMax {
+			count, err := strconv.Atoi(max)
+			if err != nil || count < 0 {
+				return RestartPolicy{}, fmt.Errorf("invalid max restarts %s", max)
+			}
+			restartPolicy.MaximumRetryCount = count
+		}
+		return restartPolicy, nil
+	}
+	return RestartPolicy{}, fmt.Errorf("invalid restart policy %s,it should be %s,%s[:max],%s or %s",
+		policy, RestartNo, RestartOnFailure, RestartAlways, RestartUnlessStopped)
+}
+
+// IsNone return whether the container is never restarted
+func (p RestartPolicy) IsNone() bool {
+	return p.Name == "" || p.Name == RestartNo
+}
+
+// ShouldRestart decide whether the exited container is started again
+func (p RestartPolicy) ShouldRestart(exitCode, restartCount int, manuallyStopped bool) bool {
+	if manuallyStopped {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount)
+	}
+	return false
+}
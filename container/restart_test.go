@@ -0,0 +1,62 @@
+package container
+
+import "testing"
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   RestartPolicy
+	}{
+		{"", RestartPolicy{Name: RestartNo}},
+		{"no", RestartPolicy{Name: RestartNo}},
+		{"always", RestartPolicy{Name: RestartAlways}},
+		{"unless-stopped", RestartPolicy{Name: RestartUnlessStopped}},
+		{"on-failure", RestartPolicy{Name: RestartOnFailure}},
+		{"on-failure:3", RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 3}},
+		{"on-failure:0", RestartPolicy{Name: RestartOnFailure}},
+	}
+	for _, test := range tests {
+		got, err := ParseRestartPolicy(test.policy)
+		if err != nil {
+			t.Errorf("%q: %v", test.policy, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q = %+v,want %+v", test.policy, got, test.want)
+		}
+	}
+	for _, policy := range []string{"sometimes", "always:3", "no:1", "on-failure:-1", "on-failure:x", "on-failure:"} {
+		if _, err := ParseRestartPolicy(policy); err == nil {
+			t.Errorf("%q: want error", policy)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy          RestartPolicy
+		exitCode, count int
+		manuallyStopped bool
+		want            bool
+	}{
+		{RestartPolicy{}, 1, 0, false, false},
+		{RestartPolicy{Name: RestartNo}, 1, 0, false, false},
+		{RestartPolicy{Name: RestartAlways}, 0, 0, false, true},
+		{RestartPolicy{Name: RestartAlways}, 137, 100, false, true},
+		{RestartPolicy{Name: RestartAlways}, 137, 0, true, false},
+		{RestartPolicy{Name: RestartUnlessStopped}, 0, 5, false, true},
+		{RestartPolicy{Name: RestartUnlessStopped}, 0, 5, true, false},
+		{RestartPolicy{Name: RestartOnFailure}, 0, 0, false, false},
+		{RestartPolicy{Name: RestartOnFailure}, 1, 1000, false, true},
+		{RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, 1, 1, false, true},
+		{RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, 1, 2, false, false},
+		{RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, 1, 0, true, false},
+	}
+	for _, test := range tests {
+		got := test.policy.ShouldRestart(test.exitCode, test.count, test.manuallyStopped)
+		if got != test.want {
+			t.Errorf("%+v exit %d restarted %d stopped %v = %v,want %v",
+				test.policy, test.exitCode, test.count, test.manuallyStopped, got, test.want)
+		}
+	}
+}
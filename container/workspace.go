@@ -0,0 +1,176 @@
+package container
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func pivotRoot(root string) error {
+	// for the new root,remount the new root
+	if err := syscall.Mount(root, root, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("Mount rootfs to itself error: %v", err)
+	}
+	// create rootfs/.pivot_root storage the old_root
+	pivotDir := filepath.Join(root, ".pivot_root")
+	if err := os.Mkdir(pivotDir, 0777); err != nil {
+		return err
+	}
+	//pivot_root to the new rootfs
+	if err := syscall.PivotRoot(root, pivotDir); err != nil {
+		return fmt.Errorf("pivot_root %v", err)
+	}
+	//edit the new work dir to the root
+	if err := syscall.Chdir("/"); err != nil {
+		return fmt.Errorf("chdir / %v", err)
+	}
+
+	pivotDir = filepath.Join("/", ".pivot_root")
+	//umount rootfs/ .pivot_root
+	if err := syscall.Unmount(pivotDir, syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("umount pivot_root dir %v", err)
+	}
+	//remove the temp file
+	return os.Remove(pivotDir)
+}
+
+func setUpMount() {
+	pwd, err := os.Getwd()
+	if err != nil {
+		log.Errorf("Get current location error")
+		return
+	}
+	log.Infof("Current location is %s", pwd)
+	pivotRoot(pwd)
+
+	//mount proc
+	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
+	syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
+	syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=75")
+}
+
+func NewWorkSpace(volume, imageName, containerName string) {
+	CreateReadOnlyLayer(RootUrl)
+	CreateWriteLayer(RootUrl)
+	CreateMountPoint(RootUrl, MntUrl)
+	CreateReadOnlyLayer(imageName)
+	CreateWriteLayer(containerName)
+	CreateMountPoint(containerName, imageName)
+	if volume != "" {
+		//analytic the volume
+		volumeURLS := volumeUrlExtract(volume)
+		length := len(volumeURLS)
+		if length == 2 && volumeURLS[0] != "" && volumeURLS[1] != "" {
+			//mount the data volume
+			MountVolume(RootUrl, MntUrl, volumeURLS)
+			log.Infof("%q", volumeURLS)
+		} else {
+			log.Infof("Volume parameter input is not correct.")
+		}
+	}
+}
+
+func MountVolume(rootURL string, mntURL string, volumeURLs []string) {
+	//create host flooder
+	parentUrl := volumeURLs[0]
+	if err := os.Mkdir(parentUrl, 0777); err != nil {
+		log.Infof("Mkdir parent dir %s error. %v", parentUrl, err)
+	}
+	//mount the point into the volume flooder
+	containeerUrl := volumeURLs[1]
+	containeerVolumeURL := mntURL + containeerUrl
+	if err := os.Mkdir(containeerVolumeURL, 0777); err != nil {
+		log.Infof("Mkdir container dir %s error. %v", containeerUrl, err)
+	}
+	//put the host mount the volume
+	dirs := "dirs=" + parentUrl
+	cmd := exec.Command("mount", "-t", "aufs", "-o", dirs, "none", containeerVolumeURL)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Errorf("Mount volume failed %v.", err)
+	}
+}
+
+// analytic the volume's string
+func volumeUrlExtract(volume string) []string {
+	var volumeURLs []string
+	volumeURLs = strings.Split(volume, ":")
+	return volumeURLs
+}
+
+// to busybox.tar unzip to the file busybox,and the container as the readonly layer
+func CreateReadOnlyLayer(rootURL string) {
+	busyboxURL := rootURL + "busybox/"
+	busyboxTarURL := rootURL + "busybox.tar"
+	exist, err := PathExists(busyboxURL)
+	if err != nil {
+		log.Infof("Fail to judge whether dir %s exist. %v", busyboxURL, err)
+	}
+	if !exist {
+		if err := os.Mkdir(busyboxTarURL, 0777); err != nil {
+			log.Infof("Mkdir dir %s error. %v", busyboxURL, err)
+		}
+		if _, err := exec.Command("tar", "-xvf", busyboxTarURL, "-C", busyboxURL).CombinedOutput(); err != nil {
+			log.Errorf("unTar dir %s error %v", busyboxTarURL, err)
+		}
+	}
+}
+
+// create the writelayer as the container's only layer
+func CreateWriteLayer(rootURL string) {
+	writeURL := rootURL + "writeLayer/"
+	if err := os.Mkdir(writeURL, 0777); err != nil {
+		log.Errorf("Mkdir dir %s error. %v", writeURL, err)
+	}
+}
+
+func CreateMountPoint(rootURL string, mntURL string) {
+	//create the file mnt as the mount point
+	if err := os.Mkdir(mntURL, 0777); err != nil {
+		log.Errorf("Mkdir dir %s error. %v", mntURL, err)
+	}
+	//put the writeLayer and busybo file and the mount to the mnt
+	dirs := "dirs=" + rootURL + "writeLayer:" + rootURL + "busybox"
+	cmd := exec.Command("mount", "-t", "aufs", "-o", dirs, "none", mntURL)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Errorf("%v", err)
+	}
+}
+
+// judge the path is exists or not
+func PathExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func DeleteMountPoint(rootURL string, mntURL string) {
+	cmd := exec.Command("umount", mntURL)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Errorf("%v", err)
+	}
+	if err := os.RemoveAll(mntURL); err != nil {
+		log.Errorf("Remove dir %s error %v", mntURL, err)
+	}
+}
+
+func DeleteWriteLayer(rootURL string) {
+	writeURL := rootURL + "writeLayer/"
+	if err := os.RemoveAll(writeURL); err != nil {
+		log.Errorf("Remove dir %s error %v", writeURL, err)
+	}
+}
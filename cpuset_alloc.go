@@ -0,0 +1,242 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"docker-my/state"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// the lock serialize the exclusive cpus allocation of the concurrent runs,
+// it is kept out of the container info location which is listed by ps
+const cpuAllocLockFile = "/var/run/mydocker-cpus.lock"
+
+// cpuAllocation is the exclusive cpus picked for a new container,the allocation lock
+// is held until the container info is recorded so no other run can pick the same cpus
+type cpuAllocation struct {
+	Cpus     string
+	Mems     string
+	lockFile *os.File
+}
+
+// Release unlock the allocation,it is safe to call more than once
+func (a *cpuAllocation) Release() {
+	if a.lockFile != nil {
+		syscall.Flock(int(a.lockFile.Fd()), syscall.LOCK_UN)
+		a.lockFile.Close()
+		a.lockFile = nil
+	}
+}
+
+// lockCpuAllocation take the allocation lock,the cpus are allocated or checked under it
+func lockCpuAllocation() (*cpuAllocation, error) {
+	lockFile, err := os.OpenFile(cpuAllocLockFile, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open cpu allocation lock %s error %v", cpuAllocLockFile, err)
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+		lockFile.Close()
+		return nil, fmt.Errorf("lock cpu allocation error %v", err)
+	}
+	return &cpuAllocation{lockFile: lockFile}, nil
+}
+
+// allocateExclusiveCpus pick n cpus no other running container has pinned,
+// the cpus of one numa node are preferred
+func allocateExclusiveCpus(n int) (*cpuAllocation, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid exclusive cpus %d", n)
+	}
+	allocation, err := lockCpuAllocation()
+	if err != nil {
+		return nil, err
+	}
+	cpus, nodes, err := pickExclusiveCpus(n)
+	if err != nil {
+		allocation.Release()
+		return nil, err
+	}
+	allocation.Cpus = subsystem.FormatCPUList(cpus)
+	allocation.Mems = subsystem.FormatCPUList(nodes)
+	return allocation, nil
+}
+
+// reserveCpuset lock the allocation and check the cpuset does not use the exclusive cpus
+// of the other containers,the lock is held until the cpuset is recorded
+func reserveCpuset(cpuset, containerName string) (*cpuAllocation, error) {
+	allocation, err := lockCpuAllocation()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExclusiveCpus(cpuset, containerName); err != nil {
+		allocation.Release()
+		return nil, err
+	}
+	allocation.Cpus = cpuset
+	return allocation, nil
+}
+
+// checkExclusiveCpus return error when the cpuset overlap the exclusive cpus of another container
+func checkExclusiveCpus(cpuset, containerName string) error {
+	cpus, err := subsystem.ParseCPUList(cpuset)
+	if err != nil {
+		return fmt.Errorf("invalid cpuset %s: %v", cpuset, err)
+	}
+	exclusive, _, err := getPinnedCpus(containerName)
+	if err != nil {
+		return err
+	}
+	for _, cpu := range cpus {
+		if owner, ok := exclusive[cpu]; ok {
+			return fmt.Errorf("cpu %d of cpuset %s is exclusive to container %s", cpu, cpuset, owner)
+		}
+	}
+	return nil
+}
+
+func pickExclusiveCpus(n int) ([]int, []int, error) {
+	online, err := readCPUListFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return nil, nil, err
+	}
+	exclusive, shared, err := getPinnedCpus("")
+	if err != nil {
+		return nil, nil, err
+	}
+	topology, err := getNumaTopology(online)
+	if err != nil {
+		return nil, nil, err
+	}
+	//the free cpus of every numa node
+	var nodes []int
+	free := make(map[int][]int)
+	total := 0
+	for node, cpus := range topology {
+		for _, cpu := range cpus {
+			if _, ok := exclusive[cpu]; !ok && !shared[cpu] {
+				free[node] = append(free[node], cpu)
+			}
+		}
+		nodes = append(nodes, node)
+		total += len(free[node])
+	}
+	if total < n {
+		return nil, nil, fmt.Errorf("no enough free cpus,%d requested but only %d are free", n, total)
+	}
+	sort.Ints(nodes)
+	//the best fit node is the one with the fewest free cpus which still can hold all the cpus
+	best := -1
+	for _, node := range nodes {
+		if len(free[node]) >= n && (best == -1 || len(free[node]) < len(free[best])) {
+			best = node
+		}
+	}
+	if best != -1 {
+		return free[best][:n], []int{best}, nil
+	}
+	//no node is large enough,spread across the nodes with the most free cpus
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return len(free[nodes[i]]) > len(free[nodes[j]])
+	})
+	var cpus, usedNodes []int
+	for _, node := range nodes {
+		if len(cpus) == n {
+			break
+		}
+		take := n - len(cpus)
+		if take > len(free[node]) {
+			take = len(free[node])
+		}
+		if take == 0 {
+			continue
+		}
+		cpus = append(cpus, free[node][:take]...)
+		usedNodes = append(usedNodes, node)
+	}
+	return cpus, usedNodes, nil
+}
+
+// getPinnedCpus return the exclusive cpus of the running containers with their owner,
+// and the cpus the other running containers are pinned to by cpuset,
+// the container of the name is skipped
+func getPinnedCpus(containerName string) (map[int]string, map[int]bool, error) {
+	containers, err := state.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	exclusive := make(map[int]string)
+	shared := make(map[int]bool)
+	for _, item := range containers {
+		if item.Name == containerName {
+			continue
+		}
+		if item.Status != container.RUNNING && item.Status != container.PAUSED && item.Status != container.RESTARTING {
+			continue
+		}
+		if item.ExclusiveCpus != "" {
+			cpus, err := subsystem.ParseCPUList(item.ExclusiveCpus)
+			if err != nil {
+				return nil, nil, fmt.Errorf("container %s has invalid exclusive cpus %s", item.Name, item.ExclusiveCpus)
+			}
+			for _, cpu := range cpus {
+				exclusive[cpu] = item.Name
+			}
+			continue
+		}
+		if item.Resources == nil || item.Resources.CpuSet == "" {
+			continue
+		}
+		cpus, err := subsystem.ParseCPUList(item.Resources.CpuSet)
+		if err != nil {
+			return nil, nil, fmt.Errorf("container %s has invalid cpuset %s", item.Name, item.Resources.CpuSet)
+		}
+		for _, cpu := range cpus {
+			shared[cpu] = true
+		}
+	}
+	return exclusive, shared, nil
+}
+
+// getNumaTopology return the online cpus of every numa node,
+// all the cpus are on the node 0 when the host has no numa info
+func getNumaTopology(online []int) (map[int][]int, error) {
+	onlineSet := make(map[int]bool)
+	for _, cpu := range online {
+		onlineSet[cpu] = true
+	}
+	nodeDirs, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil || len(nodeDirs) == 0 {
+		return map[int][]int{0: online}, nil
+	}
+	topology := make(map[int][]int)
+	for _, nodeDir := range nodeDirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(nodeDir), "node"))
+		if err != nil {
+			continue
+		}
+		cpus, err := readCPUListFile(filepath.Join(nodeDir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		for _, cpu := range cpus {
+			if onlineSet[cpu] {
+				topology[node] = append(topology[node], cpu)
+			}
+		}
+	}
+	return topology, nil
+}
+
+func readCPUListFile(path string) ([]int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s error %v", path, err)
+	}
+	return subsystem.ParseCPUList(string(content))
+}
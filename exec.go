@@ -2,7 +2,7 @@ package main
 
 import (
 	"docker-my/container"
-	"encoding/json"
+	"docker-my/state"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"os"
@@ -14,11 +14,17 @@ const ENV_EXEC_PID = "mydocker_pid"
 const ENV_EXEC_CMD = "mydocker_cmd"
 
 func ExecContainer(containerName string, comArray []string) {
-	pid, err := GetContainerPidByName(containerName)
+	containerInfo, err := state.Get(containerName)
 	if err != nil {
-		log.Errorf("Exec container getContainerPidByName %s error %v", containerName, err)
+		log.Errorf("Exec container get info %s error %v", containerName, err)
 		return
 	}
+	//a process can not join the frozen cgroup
+	if containerInfo.Status == container.PAUSED {
+		log.Errorf("Container %s is paused,unpause it first", containerName)
+		return
+	}
+	pid := containerInfo.Pid
 
 	cmdStr := strings.Join(comArray, " ")
 	log.Infof("container pid %s", pid)
@@ -39,20 +45,6 @@ func ExecContainer(containerName string, comArray []string) {
 	}
 }
 
-func GetContainerPidByName(containerName string) (string, error) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	contentBytes, err := os.ReadFile(configFilePath)
-	if err != nil {
-		return "", err
-	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		return "", err
-	}
-	return containerInfo.Pid, nil
-}
-
 func getEnvsByPid(pid string) []string {
 	path := fmt.Sprintf("/proc/%s/environ", pid)
 	contentBytes, err := os.ReadFile(path)
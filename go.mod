@@ -5,10 +5,10 @@ go 1.19
 require (
 	github.com/sirupsen/logrus v1.9.0
 	github.com/urfave/cli v1.22.12
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
 )
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"docker-my/cgroup"
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"docker-my/state"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultGroupLocation is where the record of a group is stored
+var DefaultGroupLocation = "/var/run/mydocker-group/%s/"
+
+// the cgroup every group is created under,the containers of a group are created under the group
+const groupCgroupParent = "mydocker-group"
+
+var groupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// GroupInfo is a parent cgroup shared by a set of containers,its limits cap all of them together
+type GroupInfo struct {
+	Name        string                    `json:"name"`
+	CgroupPath  string                    `json:"cgroupPath"`
+	CreatedTime string                    `json:"createTime"`
+	Resources   *subsystem.ResourceConfig `json:"resources,omitempty"`
+}
+
+// createGroup record the group and create its cgroup,the name is taken by the mkdir of
+// its dir like the container name,nothing is left when any step fail
+func createGroup(groupName string, res *subsystem.ResourceConfig) (err error) {
+	if !groupNamePattern.MatchString(groupName) {
+		return fmt.Errorf("invalid group name %s", groupName)
+	}
+	//the default parent is never resolved as a group
+	if groupName == cgroup.DefaultCgroupParent {
+		return fmt.Errorf("group name %s is reserved", groupName)
+	}
+	parent := strings.TrimSuffix(fmt.Sprintf(DefaultGroupLocation, ""), "/")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("mkdir %s error %v", parent, err)
+	}
+	dirURL := fmt.Sprintf(DefaultGroupLocation, groupName)
+	if err := os.Mkdir(dirURL, 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("group %s already exists", groupName)
+		}
+		return fmt.Errorf("mkdir %s error %v", dirURL, err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dirURL)
+		}
+	}()
+	groupInfo := &GroupInfo{
+		Name:        groupName,
+		CgroupPath:  path.Join(groupCgroupParent, groupName),
+		CreatedTime: time.Now().Format("2006-01-02 15:04:05"),
+		Resources:   res,
+	}
+	cgroupManager := cgroup.NewCGroupManager(groupInfo.CgroupPath)
+	if err := cgroupManager.Set(res); err != nil {
+		destroyCgroup(cgroupManager)
+		return fmt.Errorf("set group %s limits error %v", groupName, err)
+	}
+	jsonBytes, err := json.Marshal(groupInfo)
+	if err != nil {
+		destroyCgroup(cgroupManager)
+		return err
+	}
+	if err := state.WriteFileAtomic(dirURL+container.ConfigName, jsonBytes, 0644); err != nil {
+		destroyCgroup(cgroupManager)
+		return fmt.Errorf("write group %s config error %v", groupName, err)
+	}
+	return nil
+}
+
+func getGroupInfo(groupName string) (*GroupInfo, error) {
+	configFilePath := fmt.Sprintf(DefaultGroupLocation, groupName) + container.ConfigName
+	content, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var groupInfo GroupInfo
+	if err := json.Unmarshal(content, &groupInfo); err != nil {
+		return nil, err
+	}
+	return &groupInfo, nil
+}
+
+func getAllGroupInfo() ([]*GroupInfo, error) {
+	dirURL := strings.TrimSuffix(fmt.Sprintf(DefaultGroupLocation, ""), "/")
+	files, err := os.ReadDir(dirURL)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Read dir %s error %v", dirURL, err)
+	}
+	var groups []*GroupInfo
+	for _, file := range files {
+		groupInfo, err := getGroupInfo(file.Name())
+		if err != nil {
+			//the dir of a group being created has no config yet
+			if !os.IsNotExist(err) {
+				log.Errorf("Get group %s info error %v", file.Name(), err)
+			}
+			continue
+		}
+		groups = append(groups, groupInfo)
+	}
+	return groups, nil
+}
+
+// return the containers whose cgroup is created under the group
+func getGroupContainers(groupInfo *GroupInfo) ([]*container.ContainerInfo, error) {
+	containers, err := state.List()
+	if err != nil {
+		return nil, err
+	}
+	var members []*container.ContainerInfo
+	for _, item := range containers {
+		if strings.HasPrefix(item.CgroupPath, groupInfo.CgroupPath+"/") {
+			members = append(members, item)
+		}
+	}
+	return members, nil
+}
+
+// resolveCgroupParent return the cgroup a container is created under,the parent is a group name,
+// or a cgroup path relative to the root of the hierarchy which must be absolute or contain a "/",
+// so a mistyped group name is an error instead of a new cgroup
+func resolveCgroupParent(parent string) (string, error) {
+	if parent == "" || parent == cgroup.DefaultCgroupParent {
+		return cgroup.DefaultCgroupParent, nil
+	}
+	if !strings.Contains(parent, "/") {
+		if !groupNamePattern.MatchString(parent) {
+			return "", fmt.Errorf("no such group %s", parent)
+		}
+		groupInfo, err := getGroupInfo(parent)
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no such group %s,use a path like /%s for a cgroup", parent, parent)
+		}
+		if err != nil {
+			return "", fmt.Errorf("get group %s info error %v", parent, err)
+		}
+		return groupInfo.CgroupPath, nil
+	}
+	cgroupPath := path.Clean("/" + parent)
+	if cgroupPath == "/" {
+		return "", fmt.Errorf("invalid cgroup parent %s", parent)
+	}
+	return strings.TrimPrefix(cgroupPath, "/"), nil
+}
+
+// listGroups print the groups with the usage of all their containers,
+// the usage of a parent cgroup already includes the usage of the children
+func listGroups() error {
+	groups, err := getAllGroupInfo()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "NAME\tCGROUP\tCONTAINERS\tCPU TIME\tMEM USAGE / LIMIT\tPIDS\tCREATED\n")
+	for _, item := range groups {
+		members, err := getGroupContainers(item)
+		if err != nil {
+			return err
+		}
+		stats, err := cgroup.NewCGroupManager(item.CgroupPath).GetStats()
+		if err != nil {
+			log.Warnf("Get group %s stats error %v", item.Name, err)
+		}
+		memoryLimit := "unlimited"
+		if item.Resources != nil && item.Resources.MemoryLimit > 0 {
+			memoryLimit = formatBytes(uint64(item.Resources.MemoryLimit))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s / %s\t%d\t%s\n",
+			item.Name,
+			item.CgroupPath,
+			len(members),
+			time.Duration(stats.Cpu.UsageNanos).Round(time.Millisecond),
+			formatBytes(stats.Memory.Usage),
+			memoryLimit,
+			stats.Pids.Current,
+			item.CreatedTime)
+	}
+	return w.Flush()
+}
+
+// removeGroup delete the group,it is refused while any container is created under it
+func removeGroup(groupName string) error {
+	groupInfo, err := getGroupInfo(groupName)
+	if err != nil {
+		return fmt.Errorf("get group %s info error %v", groupName, err)
+	}
+	members, err := getGroupContainers(groupInfo)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		return fmt.Errorf("group %s is not empty,%d containers are still in it", groupName, len(members))
+	}
+	if err := cgroup.NewCGroupManager(groupInfo.CgroupPath).Destroy(); err != nil {
+		return fmt.Errorf("remove group %s cgroup error %v", groupName, err)
+	}
+	dirURL := fmt.Sprintf(DefaultGroupLocation, groupName)
+	if err := os.RemoveAll(dirURL); err != nil {
+		return fmt.Errorf("remove dir %s error %v", dirURL, err)
+	}
+	return nil
+}
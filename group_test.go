@@ -0,0 +1,146 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useTempGroupLocation store the groups of the test in a temp dir
+func useTempGroupLocation(t *testing.T) {
+	oldLocation := DefaultGroupLocation
+	DefaultGroupLocation = filepath.Join(t.TempDir(), "%s") + "/"
+	t.Cleanup(func() {
+		DefaultGroupLocation = oldLocation
+	})
+}
+
+func TestCreateGroup(t *testing.T) {
+	useTempGroupLocation(t)
+	fs := useFakeCgroupV2(t)
+	res := &subsystem.ResourceConfig{MemoryLimit: 100 << 20}
+	if err := createGroup("web", res); err != nil {
+		t.Fatal(err)
+	}
+	groupInfo, err := getGroupInfo("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupInfo.CgroupPath != "mydocker-group/web" {
+		t.Errorf("cgroup path %s", groupInfo.CgroupPath)
+	}
+	if data, err := fs.ReadFile("/sys/fs/cgroup/mydocker-group/web/memory.max"); err != nil || string(data) != "104857600" {
+		t.Errorf("memory.max %q,%v", data, err)
+	}
+	//no temp file is left next to the config
+	entries, err := os.ReadDir(fmt.Sprintf(DefaultGroupLocation, "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != container.ConfigName {
+		t.Errorf("group dir has %v", entries)
+	}
+	if err := createGroup("web", res); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("create the group again error %v", err)
+	}
+}
+
+func TestCreateGroupConcurrent(t *testing.T) {
+	useTempGroupLocation(t)
+	useFakeCgroupV2(t)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = createGroup("db", &subsystem.ResourceConfig{})
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		} else if !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("create group error %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("the group is created %d times", created)
+	}
+}
+
+func TestCreateGroupFailure(t *testing.T) {
+	useTempGroupLocation(t)
+	fs := useFakeCgroupV2(t)
+	//the hugetlb controller is not available,setting the limit fail
+	res := &subsystem.ResourceConfig{HugetlbLimit: []*subsystem.HugepageLimit{{PageSize: "2MB", Limit: 1 << 21}}}
+	if err := createGroup("batch", res); err == nil {
+		t.Fatal("create group with an unavailable controller succeeded")
+	}
+	if _, err := os.Stat(fmt.Sprintf(DefaultGroupLocation, "batch")); !os.IsNotExist(err) {
+		t.Errorf("group dir is left,stat error %v", err)
+	}
+	if _, err := fs.Stat("/sys/fs/cgroup/mydocker-group/batch"); err == nil {
+		t.Error("group cgroup is left")
+	}
+	//the name can be taken again
+	if err := createGroup("batch", &subsystem.ResourceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateGroupInvalidName(t *testing.T) {
+	useTempGroupLocation(t)
+	useFakeCgroupV2(t)
+	for _, name := range []string{"", "a/b", "../x", "mydocker"} {
+		if err := createGroup(name, &subsystem.ResourceConfig{}); err == nil {
+			t.Errorf("create group %q succeeded", name)
+		}
+	}
+}
+
+func TestResolveCgroupParent(t *testing.T) {
+	useTempGroupLocation(t)
+	useFakeCgroupV2(t)
+	if err := createGroup("web", &subsystem.ResourceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		parent string
+		want   string
+		err    bool
+	}{
+		{parent: "", want: "mydocker"},
+		{parent: "mydocker", want: "mydocker"},
+		{parent: "web", want: "mydocker-group/web"},
+		{parent: "/web", want: "web"},
+		{parent: "/system.slice/", want: "system.slice"},
+		{parent: "a/b/../c", want: "a/c"},
+		{parent: "wbe", err: true},
+		{parent: "bad name", err: true},
+		{parent: "/", err: true},
+		{parent: "/..", err: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveCgroupParent(tt.parent)
+		if tt.err {
+			if err == nil {
+				t.Errorf("resolve %q = %q,want error", tt.parent, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolve %q = %q,%v,want %q", tt.parent, got, err, tt.want)
+		}
+	}
+	if _, err := resolveCgroupParent("wbe"); err == nil || !strings.Contains(err.Error(), "no such group") {
+		t.Errorf("resolve a mistyped group error %v", err)
+	}
+}
@@ -17,11 +17,23 @@ func main() {
 		runCommand,
 		commitCommand,
 		listCommand,
-		logCommmand,
+		logCommand,
+		inspectCommand,
+		execCommand,
+		stopCommand,
+		removeCommand,
+		pauseCommand,
+		unpauseCommand,
+		statsCommand,
+		updateCommand,
+		groupCommand,
+		usageCommand,
+		waitCommand,
+		monitorCommand,
 	}
 	app.Before = func(context *cli.Context) error {
-		//Log as Json instant of the default ASCII formatter
-		log.SetOutput(os.Stdout)
+		//the logs go to stderr,the stdout only carry the output of the commands like ps and wait
+		log.SetOutput(os.Stderr)
 		return nil
 	}
 	if err := app.Run(os.Args); err != nil {
@@ -4,23 +4,25 @@ import (
 	"docker-my/cgroup"
 	"docker-my/cgroup/subsystem"
 	"docker-my/container"
+	"docker-my/state"
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 	"io"
-	"io/ioutil"
 	"os"
+	"os/exec"
 	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
+	"time"
 )
 
 var runCommand = cli.Command{
 	Name:  "run",
 	Usage: "Create a container with namespace and cgroups limit mydocker run -ti [command]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{
 			Name:  "ti",
 			Usage: "enable tty",
@@ -30,26 +32,38 @@ var runCommand = cli.Command{
 			Usage: "detach container",
 		},
 		cli.StringFlag{
-			Name:  "m",
-			Usage: "memory limit",
+			Name:  "v",
+			Usage: "volume",
 		},
 		cli.StringFlag{
-			Name:  "cpushare",
-			Usage: "cpushare limit",
+			Name:  "name",
+			Usage: "container name",
+		},
+		cli.IntFlag{
+			Name:  "cpus-exclusive",
+			Usage: "pin the container to n cpus no other running container use",
 		},
 		cli.StringFlag{
-			Name:  "cpuset",
-			Usage: "cpuset limit",
+			Name:  "cgroupns",
+			Usage: "cgroup namespace of the container,private or host",
+			Value: container.CgroupnsPrivate,
 		},
 		cli.StringFlag{
-			Name:  "v",
-			Usage: "volume",
+			Name:  "cgroup-parent",
+			Usage: "the group or the cgroup path (absolute or containing a /) the container's cgroup is created under",
+			Value: cgroup.DefaultCgroupParent,
 		},
 		cli.StringFlag{
-			Name:  "name",
-			Usage: "container name",
+			Name:  "restart",
+			Usage: "restart policy when the container exits,no,on-failure[:max],always or unless-stopped",
+			Value: container.RestartNo,
 		},
-	},
+		cli.DurationFlag{
+			Name:  "usage-interval",
+			Usage: "interval to record the resource usage of the container,0 to disable",
+			Value: DefaultUsageInterval,
+		},
+	}, resourceFlags...),
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container command")
@@ -63,17 +77,49 @@ var runCommand = cli.Command{
 		if tty && detach {
 			return fmt.Errorf("ti and d patameter can not both provided")
 		}
-		// put the volume's param to the Run method
-		//volume := context.String("v")
-		resConf := &subsystem.ResourceConfig{
-			MemoryLimit: context.String("m"),
-			CpuSet:      context.String("cpuset"),
-			CpuShare:    context.String("cputshare"),
+		resConf := &subsystem.ResourceConfig{}
+		if err := parseResourceConfig(context, resConf); err != nil {
+			return err
+		}
+		cpusExclusive := context.Int("cpus-exclusive")
+		if cpusExclusive != 0 && resConf.CpuSet != "" {
+			return fmt.Errorf("cpus-exclusive and cpuset can not both provided")
+		}
+		cgroupns, err := container.ParseCgroupns(context.String("cgroupns"))
+		if err != nil {
+			return err
+		}
+		cgroupParent, err := resolveCgroupParent(context.String("cgroup-parent"))
+		if err != nil {
+			return err
+		}
+		restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+		if err != nil {
+			return err
+		}
+		//only the monitor of a detached container outlive the terminal to restart it
+		if tty && !restartPolicy.IsNone() {
+			return fmt.Errorf("ti and restart parameter can not both provided")
 		}
 		log.Infof("createTty %v", tty)
 		containerName := context.String("name")
-		Run(tty, cmdArray, resConf, containerName)
-		return nil
+		usageInterval := context.Duration("usage-interval")
+		if usageInterval < 0 {
+			return fmt.Errorf("invalid usage interval %s", usageInterval)
+		}
+		opts := &runOptions{
+			Tty:           tty,
+			Command:       cmdArray,
+			Resources:     resConf,
+			Name:          containerName,
+			Volume:        context.String("v"),
+			CpusExclusive: cpusExclusive,
+			Cgroupns:      cgroupns,
+			CgroupParent:  cgroupParent,
+			UsageInterval: usageInterval,
+			Restart:       restartPolicy,
+		}
+		return Run(opts)
 	},
 }
 
@@ -89,43 +135,213 @@ var initCommand = cli.Command{
 	},
 }
 
-func Run(tty bool, comArray []string, res *subsystem.ResourceConfig, containerName, volume, imageName string) {
-	parent, writePipe := container.NewParentProcess(tty, containerName, volume, imageName)
-	if parent == nil {
-		log.Errorf("New parent process error")
-		return
+// runOptions is everything needed to run a container,the monitor of a detached
+// container get it from the run command through a pipe
+type runOptions struct {
+	Tty           bool                      `json:"tty"`
+	Command       []string                  `json:"command"`
+	Resources     *subsystem.ResourceConfig `json:"resources"`
+	Id            string                    `json:"id"`
+	Name          string                    `json:"name"`
+	Volume        string                    `json:"volume"`
+	ImageName     string                    `json:"imageName"`
+	CpusExclusive int                       `json:"cpusExclusive"`
+	Cgroupns      string                    `json:"cgroupns"`
+	CgroupParent  string                    `json:"cgroupParent"`
+	UsageInterval time.Duration             `json:"usageInterval"`
+	Restart       container.RestartPolicy   `json:"restart"`
+}
+
+// runningContainer is a started container process with its cgroup
+type runningContainer struct {
+	opts          *runOptions
+	process       *exec.Cmd
+	cgroupManager *cgroup.CgroupManager
+	startedTime   time.Time
+}
+
+func Run(opts *runOptions) error {
+	containerID, err := container.NewContainerID()
+	if err != nil {
+		return err
 	}
-	if err := parent.Start(); err != nil {
-		log.Error(err)
+	opts.Id = containerID
+	if opts.Name == "" {
+		opts.Name = container.ShortID(containerID)
 	}
-	//record the container info
-	containerName, err := container.RecordContainerInfo(parent.Process.Pid, comArray, containerName)
+	//take the name before the log and the device nodes are written to its dir,
+	//it is given back when the container is never recorded
+	if err := state.Reserve(opts.Name); err != nil {
+		return err
+	}
+	//a detached container is started by its monitor,which outlive the run command
+	if !opts.Tty {
+		if err := startMonitor(opts); err != nil {
+			releaseName(opts.Name)
+			return err
+		}
+		return nil
+	}
+	//the run command itself is the monitor of a tty container
+	rc, err := startContainer(opts)
 	if err != nil {
-		log.Errorf("Record container info error %v", err)
-		return
+		releaseName(opts.Name)
+		return err
+	}
+	superviseContainer(rc)
+	return nil
+}
+
+func releaseName(containerName string) {
+	if err := state.Release(containerName); err != nil {
+		log.Warnf("Release container name %s error %v", containerName, err)
+	}
+}
+
+// startContainer start the container process as a child of the caller and record it,
+// the caller is the monitor of the container and must wait for the process
+func startContainer(opts *runOptions) (*runningContainer, error) {
+	res := opts.Resources
+	var allocation *cpuAllocation
+	var exclusiveCpus string
+	if opts.CpusExclusive > 0 {
+		//the allocation is locked until the container info is recorded
+		var err error
+		if allocation, err = allocateExclusiveCpus(opts.CpusExclusive); err != nil {
+			return nil, fmt.Errorf("allocate exclusive cpus error %v", err)
+		}
+		defer allocation.Release()
+		exclusiveCpus = allocation.Cpus
+		res.CpuSet = allocation.Cpus
+		if res.CpuSetMems == "" {
+			res.CpuSetMems = allocation.Mems
+		}
+	} else if res.CpuSet != "" {
+		//the cpuset is checked against the exclusive cpus under the same lock
+		var err error
+		if allocation, err = reserveCpuset(res.CpuSet, opts.Name); err != nil {
+			return nil, err
+		}
+		defer allocation.Release()
 	}
-	//use docker-cgroup as cgroup name
 	//create cgroupmanager,and use the apply and set for the resource limit
-	cgroupManager := cgroup.NewCGroupManager("mydocker-cgroup")
-	defer cgroupManager.Destroy()
-	//set the resource limit
-	cgroupManager.Set(res)
+	//every container own its cgroup under the parent,just like mydocker/<id>
+	//the cgroup live as long as the container,it is removed by rm or the exit of a tty container
+	cgroupPath := cgroup.ContainerCgroupPath(opts.CgroupParent, opts.Id)
+	cgroupManager := cgroup.NewCGroupManager(cgroupPath)
+	//set the resource limit before the container process exist,nothing is left when it fail
+	if err := cgroupManager.Set(res); err != nil {
+		destroyCgroup(cgroupManager)
+		return nil, fmt.Errorf("set cgroup %s error: %v", cgroupPath, err)
+	}
+	parent, writePipe := container.NewParentProcess(opts.Tty, opts.Name, opts.Volume, opts.ImageName, opts.Cgroupns)
+	if parent == nil {
+		destroyCgroup(cgroupManager)
+		return nil, fmt.Errorf("new parent process error")
+	}
+	if err := container.CreateDeviceNodes(opts.Name, res.Devices); err != nil {
+		destroyCgroup(cgroupManager)
+		return nil, fmt.Errorf("create device nodes error %v", err)
+	}
+	if err := parent.Start(); err != nil {
+		destroyCgroup(cgroupManager)
+		return nil, fmt.Errorf("start container process error %v", err)
+	}
+	//the init process is blocked on the pipe until the command is sent,
+	//so it is killed before running anything when the setup fail
+	abort := func(err error) error {
+		writePipe.Close()
+		parent.Process.Kill()
+		parent.Wait()
+		destroyCgroup(cgroupManager)
+		return err
+	}
 	//add the docker process to the cgroup
-	cgroupManager.Apply(parent.Process.Pid)
+	if err := cgroupManager.Apply(parent.Process.Pid); err != nil {
+		return nil, abort(fmt.Errorf("apply cgroup %s error: %v", cgroupPath, err))
+	}
+	//record the container info
+	containerInfo := &container.ContainerInfo{
+		Id:            opts.Id,
+		Pid:           strconv.Itoa(parent.Process.Pid),
+		MonitorPid:    os.Getpid(),
+		Command:       strings.Join(opts.Command, " "),
+		CreatedTime:   time.Now().Format("2006-01-02 15:04:05"),
+		Status:        container.RUNNING,
+		Name:          opts.Name,
+		Volume:        opts.Volume,
+		CgroupPath:    cgroupPath,
+		Resources:     res,
+		ExclusiveCpus: exclusiveCpus,
+		RestartPolicy: opts.Restart,
+	}
+	if err := state.Create(containerInfo); err != nil {
+		return nil, abort(fmt.Errorf("record container info error %v", err))
+	}
+	if allocation != nil {
+		allocation.Release()
+	}
 	//init the docker
-	sendInitCommand(comArray, writePipe)
-	if tty {
-		parent.Wait()
-		container.DeleteContainerInfo(containerName)
+	sendInitCommand(opts.Command, writePipe)
+	return &runningContainer{opts: opts, process: parent, cgroupManager: cgroupManager, startedTime: time.Now()}, nil
+}
+
+// superviseContainer wait for the container process to exit and record how it exited,
+// the container is started again as its restart policy say,
+// the usage and the oom kill are recorded meanwhile
+func superviseContainer(rc *runningContainer) {
+	opts := rc.opts
+	stopUsage := func() {}
+	if opts.UsageInterval > 0 {
+		if stop, err := recordUsage(opts.Name, rc.cgroupManager.Path, opts.UsageInterval); err != nil {
+			log.Warnf("Record usage of container %s error %v", opts.Name, err)
+		} else {
+			stopUsage = stop
+		}
 	}
-	container.DeleteWorkSpace(volume, imageName, containerName)
+	watchOOM(opts.Name, rc.cgroupManager)
+	var backoff restartBackoff
+	for {
+		rc.process.Wait()
+		closeLogFile(rc.process)
+		//the record is kept as exited until rm,so the exit code and the usage history can still be read
+		if !recordExit(opts.Name, rc.process.ProcessState) {
+			break
+		}
+		delay := backoff.next(time.Since(rc.startedTime))
+		log.Infof("Restart container %s in %s", opts.Name, delay)
+		if !sleepUnlessStopped(opts.Name, delay) {
+			stopRestarting(opts.Name)
+			break
+		}
+		if err := restartContainer(rc); err != nil {
+			log.Errorf("Restart container %s error %v", opts.Name, err)
+			stopRestarting(opts.Name)
+			break
+		}
+	}
+	stopUsage()
+	if count, err := rc.cgroupManager.OOMKillCount(); err == nil && count > 0 {
+		log.Warnf("Container %s is killed by the oom killer", opts.Name)
+	}
+	if opts.Tty {
+		destroyCgroup(rc.cgroupManager)
+	}
+	container.DeleteWorkSpace(opts.Volume, opts.ImageName, opts.Name)
+}
 
+// remove the cgroup of the container,the failure is only logged since nothing else can be done
+func destroyCgroup(cgroupManager *cgroup.CgroupManager) {
+	if err := cgroupManager.Destroy(); err != nil {
+		log.Warnf("Destroy cgroup %s error %v", cgroupManager.Path, err)
+	}
 }
 
 func sendInitCommand(comArray []string, writePipe *os.File) {
-	command := strings.Join(comArray, " ")
-	log.Infof("command all is %s", command)
-	writePipe.WriteString(command)
+	log.Infof("command all is %q", comArray)
+	if err := container.WriteInitCommand(writePipe, comArray); err != nil {
+		log.Errorf("Send init command error %v", err)
+	}
 	writePipe.Close()
 }
 
@@ -153,34 +369,28 @@ var listCommand = cli.Command{
 }
 
 func ListContainers() {
-	//find the path for the storage /var/run/mydocker
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
-	dirURL = dirURL[:len(dirURL)-1]
-	//read all the fd in this file
-	files, err := os.ReadDir(dirURL)
+	containers, err := state.List()
 	if err != nil {
-		log.Errorf("Read dir %s error %v", dirURL, err)
+		log.Error(err)
 		return
 	}
-	var containers []*container.ContainerInfo
-	for _, file := range files {
-		//according to the config info,transfer the container instance
-		tempContainer, err := getContainerInfo(file)
-		if err != nil {
-			log.Errorf("Get container info error %v", err)
-			continue
-		}
-		containers = append(containers, tempContainer)
-	}
 	//print info
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	fmt.Fprintf(w, "ID\tName\tPID\tSTATUS\tCOMMAND\tCREATED\n")
 	for _, item := range containers {
+		refreshOOMStatus(item)
+		status := item.Status
+		if item.Status == container.EXIT || item.Status == container.RESTARTING {
+			status = fmt.Sprintf("%s (%d)", item.Status, item.ExitCode)
+		}
+		if item.OOMKilled {
+			status += " (OOMKilled)"
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+			container.ShortID(item.Id),
 			item.Name,
 			item.Pid,
-			item.Status,
+			status,
 			item.Command,
 			item.CreatedTime)
 	}
@@ -191,35 +401,17 @@ func ListContainers() {
 	}
 }
 
-func getContainerInfo(file os.FileInfo) (*container.ContainerInfo, error) {
-	// get the fileName
-	containerName := file.Name()
-	//according to the fileName create the absolute path
-	configFileDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFileDir = configFileDir + container.ConfigName
-	//read the config info
-	content, err := os.ReadFile(configFileDir)
-	if err != nil {
-		log.Errorf("Read file %s error %v", configFileDir, err)
-		return nil, err
-	}
-	var containerInfo container.ContainerInfo
-	// serialize the json file
-	if err := json.Unmarshal(content, &containerInfo); err != nil {
-		log.Errorf("Json unmarshal error %v", err)
-		return nil, err
-	}
-	return &containerInfo, nil
-}
-
-var logCommadn = cli.Command{
+var logCommand = cli.Command{
 	Name:  "logs",
 	Usage: "print logs of a container",
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Please input your container name")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		logContainer(containerName)
 		return nil
 	},
@@ -248,126 +440,342 @@ var execCommand = cli.Command{
 	Action: func(context *cli.Context) error {
 		//This is for callback
 		if os.Getenv(ENV_EXEC_PID) != "" {
-			log.Infof("pid call back pid %s", os.Getppid())
+			log.Infof("pid call back pid %d", os.Getppid())
 			return nil
 		}
 		if len(context.Args()) < 2 {
 			return fmt.Errorf("Missing container name or command")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		var commandArray []string
 		for _, arg := range context.Args().Tail() {
 			commandArray = append(commandArray, arg)
 		}
 		//exec the order
-		ExexContainer(containerName, commandArray)
+		ExecContainer(containerName, commandArray)
 		return nil
 	},
 }
 
-func getContainerPidByName(containerName string) (string, error) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	contentBytes, err := os.ReadFile(configFilePath)
-	if err != nil {
-		return "", err
-	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		return "", err
-	}
-	return containerInfo.Pid, nil
-}
-
 var stopCommand = cli.Command{
 	Name:  "stop",
 	Usage: "Stop a container",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "time, t",
+			Value: 10,
+			Usage: "seconds to wait for the container to exit before killing it",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		stopContainer(containerName)
-		return nil
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return stopContainer(containerName, time.Duration(context.Int("time"))*time.Second)
 	},
 }
 
-func getContainerInfoByName(containerName string) (*container.ContainerInfo, error) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	contentBytes, err := ioutil.ReadFile(configFilePath)
+// stopContainer send SIGTERM to the container and wait for its monitor to record the exit,
+// the container is killed when it does not exit in the timeout and it is not restarted anymore
+func stopContainer(containerName string, timeout time.Duration) error {
+	//the monitor never restart a container marked as stopped
+	containerInfo, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.RUNNING && info.Status != container.PAUSED && info.Status != container.RESTARTING {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		info.ManuallyStopped = true
+		return nil
+	})
 	if err != nil {
-		log.Errorf("Read file %s error %v", configFilePath, err)
-		return nil, err
-	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		log.Errorf("GetContainerInfoByName unmarshal error %v", err)
-		return nil, err
+		return err
 	}
-	return &containerInfo, nil
-}
-
-func stopContainer(containerName string) {
-	pid, err := GetContainerPidByName(containerName)
-	if err != nil {
-		log.Errorf("Get contaienr pid by name %s error %v", containerName, err)
-		return
+	//a container waiting for the restart has no process,its monitor give up the restart
+	if containerInfo.Status == container.RESTARTING {
+		exited, err := waitContainerExit(containerName, containerInfo.MonitorPid, timeout)
+		if err == nil && !exited {
+			return fmt.Errorf("container %s does not exit in %s", containerName, timeout)
+		}
+		return err
 	}
-	pidInt, err := strconv.Atoi(pid)
+	pidInt, err := strconv.Atoi(containerInfo.Pid)
 	if err != nil {
-		log.Errorf("Conver pid from string to int error %v", err)
-		return
+		return fmt.Errorf("Conver pid from string to int error %v", err)
 	}
 	if err := syscall.Kill(pidInt, syscall.SIGTERM); err != nil {
-		log.Errorf("Stop container %s error %v", containerName, err)
-		return
+		return fmt.Errorf("Stop container %s error %v", containerName, err)
 	}
-	containerInfo, err := getContainerInfoByName(containerName)
-	if err != nil {
-		log.Errorf("Get container %s info error %v", containerName, err)
-		return
+	//the signal is pending until the frozen container is thawed
+	if containerInfo.Status == container.PAUSED {
+		if err := cgroup.NewCGroupManager(containerInfo.CgroupPath).Freeze(subsystem.Thawed); err != nil {
+			log.Errorf("Thaw container %s error %v", containerName, err)
+		}
+	}
+	//the container recorded before the monitor has nobody to record its exit
+	if containerInfo.MonitorPid == 0 {
+		_, err := state.Update(containerName, markStopped)
+		return err
+	}
+	exited, err := waitContainerExit(containerName, containerInfo.MonitorPid, timeout)
+	if err != nil || exited {
+		return err
+	}
+	log.Warnf("Container %s does not exit in %s,kill it", containerName, timeout)
+	if err := syscall.Kill(pidInt, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("Kill container %s error %v", containerName, err)
 	}
+	if exited, err = waitContainerExit(containerName, containerInfo.MonitorPid, timeout); err == nil && !exited {
+		return fmt.Errorf("container %s does not exit after killed", containerName)
+	}
+	return err
+}
+
+// markStopped is the state change of a container whose process is gone
+func markStopped(containerInfo *container.ContainerInfo) error {
 	containerInfo.Status = container.STOP
 	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
+	//release the exclusive cpus,a stopped container never pin the cpus
+	containerInfo.ExclusiveCpus = ""
+	return nil
+}
+
+func removeContainer(containerName string) {
+	err := state.Remove(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.STOP && containerInfo.Status != container.EXIT {
+			return fmt.Errorf("Couldn't remove running container")
+		}
+		if containerInfo.CgroupPath != "" {
+			destroyCgroup(cgroup.NewCGroupManager(containerInfo.CgroupPath))
+		}
+		return nil
+	})
 	if err != nil {
-		log.Errorf("Json marshal %s error %v", containerName, err)
-		return
-	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		log.Errorf("Write file %s error", configFilePath, err)
+		log.Errorf("Remove container %s error %v", containerName, err)
 	}
 }
 
-func removeContainer(containerName string) {
-	containerInfo, err := getContainerInfoByName(containerName)
+var removeCommand = cli.Command{
+	Name:  "rm",
+	Usage: "remove unused containers",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		removeContainer(containerName)
+		return nil
+	},
+}
+
+var inspectCommand = cli.Command{
+	Name:  "inspect",
+	Usage: "display the detail info of a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		inspectContainer(containerName)
+		return nil
+	},
+}
+
+// containerDetail is the container info with the usage read from its cgroup
+type containerDetail struct {
+	*container.ContainerInfo
+	Stats *subsystem.Stats `json:"stats,omitempty"`
+}
+
+func inspectContainer(containerName string) {
+	containerInfo, err := state.Get(containerName)
 	if err != nil {
 		log.Errorf("Get container %s info error %v", containerName, err)
 		return
 	}
-	if containerInfo.Status != container.STOP {
-		log.Errorf("Couldn't remove running container")
-		return
+	refreshOOMStatus(containerInfo)
+	detail := &containerDetail{ContainerInfo: containerInfo}
+	if containerInfo.Status == container.RUNNING && containerInfo.CgroupPath != "" {
+		stats, err := cgroup.NewCGroupManager(containerInfo.CgroupPath).GetStats()
+		if err != nil {
+			log.Warnf("Get container %s stats error %v", containerName, err)
+		}
+		detail.Stats = stats
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(dirURL); err != nil {
-		log.Errorf("Remove file %s error %v", dirURL, err)
+	contentBytes, err := json.MarshalIndent(detail, "", "    ")
+	if err != nil {
+		log.Errorf("Json marshal %s error %v", containerName, err)
 		return
 	}
+	fmt.Fprintln(os.Stdout, string(contentBytes))
 }
 
-var removeCommand = cli.Command{
-	Name:  "rm",
-	Usage: "remove unused containers",
+var pauseCommand = cli.Command{
+	Name:  "pause",
+	Usage: "pause all processes within a container",
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		removeContainer(containerName)
-		return nil
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return pauseContainer(containerName)
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:  "unpause",
+	Usage: "unpause all processes within a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return unpauseContainer(containerName)
+	},
+}
+
+var statsCommand = cli.Command{
+	Name:  "stats",
+	Usage: "display a live stream of containers resource usage,mydocker stats [NAME...]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "print the first result and exit",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format,table or json",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		format := context.String("format")
+		if format != "table" && format != "json" {
+			return fmt.Errorf("unknown format %s,use table or json", format)
+		}
+		return statsContainers(context.Args(), context.Bool("no-stream"), format)
+	},
+}
+
+var updateCommand = cli.Command{
+	Name:  "update",
+	Usage: "update the resource limit of a running container,mydocker update [flags] NAME",
+	Flags: resourceFlags,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return updateContainer(containerName, context)
+	},
+}
+
+var groupCommand = cli.Command{
+	Name:  "group",
+	Usage: "manage the groups sharing the resource limits among containers,use --cgroup-parent to run a container in a group",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create a group with the resource limits,mydocker group create [flags] NAME",
+			Flags: resourceFlags,
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing group name")
+				}
+				resConf := &subsystem.ResourceConfig{}
+				if err := parseResourceConfig(context, resConf); err != nil {
+					return err
+				}
+				return createGroup(context.Args().Get(0), resConf)
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "list the groups with the usage of their containers",
+			Action: func(context *cli.Context) error {
+				return listGroups()
+			},
+		},
+		{
+			Name:  "rm",
+			Usage: "remove empty groups,mydocker group rm NAME...",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing group name")
+				}
+				for _, name := range context.Args() {
+					if err := removeGroup(name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var usageCommand = cli.Command{
+	Name:  "usage",
+	Usage: "print the recorded resource usage of a container,also after it exited,mydocker usage NAME",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := state.Resolve(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return printUsage(containerName)
+	},
+}
+
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "block until the containers stop and print their exit codes,mydocker wait NAME...",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "condition",
+			Value: WaitConditionNotRunning,
+			Usage: "the condition to wait for,not-running,next-exit or removed",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "give up waiting after the duration,it is the total for all the containers,0 to wait forever",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		return waitContainers(context.Args(), context.String("condition"), context.Duration("timeout"))
+	},
+}
+
+var monitorCommand = cli.Command{
+	Name:   "monitor",
+	Usage:  "start a detached container and stay as its parent until it exits.Do not call it outside",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		return runMonitor()
 	},
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStartContainerRollbackCgroup(t *testing.T) {
+	fs := useFakeCgroupV2(t)
+	//the memory limit is written,the hugetlb one fail since the controller is not available
+	opts := &runOptions{
+		Id:      "abc",
+		Name:    "abc",
+		Command: []string{"sh"},
+		Resources: &subsystem.ResourceConfig{
+			MemoryLimit:  64 << 20,
+			HugetlbLimit: []*subsystem.HugepageLimit{{PageSize: "2MB", Limit: 1 << 21}},
+		},
+	}
+	_, err := startContainer(opts)
+	if err == nil || !strings.Contains(err.Error(), "hugetlb") {
+		t.Fatalf("start container error %v,want the hugetlb error", err)
+	}
+	if _, err := fs.Stat("/sys/fs/cgroup/mydocker/abc"); !os.IsNotExist(err) {
+		t.Errorf("cgroup of the container is left,stat error %v", err)
+	}
+}
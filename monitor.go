@@ -0,0 +1,299 @@
+package main
+
+import (
+	"docker-my/container"
+	"docker-my/state"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// the output of the monitor of a detached container,it is kept in the dir of the container
+const monitorLogFile = "monitor.log"
+
+// monitorReply is sent by the monitor to the run command once the container is started
+type monitorReply struct {
+	Error string `json:"error,omitempty"`
+}
+
+// startMonitor start the monitor of a detached container in a new session,the options are sent
+// through the fd 3 and the run command wait on the fd 4 until the container is started
+func startMonitor(opts *runOptions) error {
+	optsRead, optsWrite, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error %v", err)
+	}
+	defer optsWrite.Close()
+	replyRead, replyWrite, err := os.Pipe()
+	if err != nil {
+		optsRead.Close()
+		return fmt.Errorf("new pipe error %v", err)
+	}
+	defer replyRead.Close()
+	logFilePath := state.Dir(opts.Name) + monitorLogFile
+	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		optsRead.Close()
+		replyWrite.Close()
+		return fmt.Errorf("open monitor log %s error %v", logFilePath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command("/proc/self/exe", "monitor")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{optsRead, replyWrite}
+	err = cmd.Start()
+	optsRead.Close()
+	replyWrite.Close()
+	if err != nil {
+		return fmt.Errorf("start monitor error %v", err)
+	}
+	if err := json.NewEncoder(optsWrite).Encode(opts); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("send options to monitor error %v", err)
+	}
+	optsWrite.Close()
+	var reply monitorReply
+	if err := json.NewDecoder(replyRead).Decode(&reply); err != nil {
+		cmd.Wait()
+		return fmt.Errorf("monitor of container %s exited before starting it", opts.Name)
+	}
+	if reply.Error != "" {
+		cmd.Wait()
+		return errors.New(reply.Error)
+	}
+	log.Infof("container %s is started by monitor %d", opts.Name, cmd.Process.Pid)
+	return cmd.Process.Release()
+}
+
+// runMonitor is the body of the hidden monitor command,it start the container as its child
+// and stay until the container exit
+func runMonitor() error {
+	optsPipe := os.NewFile(uintptr(3), "options")
+	replyPipe := os.NewFile(uintptr(4), "reply")
+	var opts runOptions
+	err := json.NewDecoder(optsPipe).Decode(&opts)
+	optsPipe.Close()
+	var rc *runningContainer
+	if err != nil {
+		err = fmt.Errorf("read options error %v", err)
+	} else {
+		rc, err = startContainer(&opts)
+	}
+	reply := monitorReply{}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	if err := json.NewEncoder(replyPipe).Encode(&reply); err != nil {
+		log.Warnf("Reply to run error %v", err)
+	}
+	replyPipe.Close()
+	if err != nil {
+		return err
+	}
+	superviseContainer(rc)
+	return nil
+}
+
+const (
+	// how often the info is checked while waiting for the container
+	statePollInterval = 100 * time.Millisecond
+
+	restartDelayMin = 100 * time.Millisecond
+	restartDelayMax = time.Minute
+	// a container running longer than this is fine,the delay of its next restart start over
+	restartResetAfter = 10 * time.Second
+)
+
+// restartBackoff double the delay before every restart of a container which keep exiting quickly
+type restartBackoff struct {
+	delay time.Duration
+}
+
+func (b *restartBackoff) next(ran time.Duration) time.Duration {
+	if b.delay == 0 || ran >= restartResetAfter {
+		b.delay = restartDelayMin
+	} else if b.delay *= 2; b.delay > restartDelayMax {
+		b.delay = restartDelayMax
+	}
+	return b.delay
+}
+
+// the exit code of a process killed by a signal is 128 plus the signal like the shell
+func exitStatus(processState *os.ProcessState) (int, string) {
+	if processState == nil {
+		return -1, ""
+	}
+	status, ok := processState.Sys().(syscall.WaitStatus)
+	if !ok {
+		return processState.ExitCode(), ""
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal()), unix.SignalName(status.Signal())
+	}
+	return status.ExitStatus(), ""
+}
+
+// recordExit record how the container process finished and return whether it is restarted
+func recordExit(containerName string, processState *os.ProcessState) bool {
+	exitCode, signal := exitStatus(processState)
+	log.Infof("Container %s exited with code %d", containerName, exitCode)
+	restart := false
+	_, err := state.Update(containerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.Pid = " "
+		containerInfo.ExitCode = exitCode
+		containerInfo.ExitSignal = signal
+		containerInfo.FinishedTime = time.Now().Format("2006-01-02 15:04:05")
+		//decided under the lock,so a stop command in progress is always seen
+		restart = containerInfo.RestartPolicy.ShouldRestart(exitCode, containerInfo.RestartCount, containerInfo.ManuallyStopped)
+		if restart {
+			//the exclusive cpus are still pinned by the cgroup waiting for the restart
+			containerInfo.Status = container.RESTARTING
+			return nil
+		}
+		return markExited(containerInfo)
+	})
+	if err != nil {
+		log.Errorf("Update container %s info error %v", containerName, err)
+		return false
+	}
+	return restart
+}
+
+// markExited is the state change of a container which is not going to run again
+func markExited(containerInfo *container.ContainerInfo) error {
+	containerInfo.Status = container.EXIT
+	containerInfo.Pid = " "
+	containerInfo.MonitorPid = 0
+	//an exited container never pin the cpus
+	containerInfo.ExclusiveCpus = ""
+	return nil
+}
+
+// stopRestarting give up the pending restart of a container
+func stopRestarting(containerName string) {
+	if _, err := state.Update(containerName, markExited); err != nil {
+		log.Errorf("Update container %s info error %v", containerName, err)
+	}
+}
+
+// sleepUnlessStopped wait for the restart delay,it return false as soon as the container is stopped
+func sleepUnlessStopped(containerName string, delay time.Duration) bool {
+	deadline := time.Now().Add(delay)
+	for {
+		containerInfo, err := state.Get(containerName)
+		if err != nil {
+			log.Errorf("Get container %s info error %v", containerName, err)
+			return false
+		}
+		if containerInfo.ManuallyStopped {
+			return false
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return true
+		}
+		if left > statePollInterval {
+			left = statePollInterval
+		}
+		time.Sleep(left)
+	}
+}
+
+// restartContainer start a new init process in the rootfs and the cgroup of the exited one,
+// the limits of the cgroup are kept so the restarted container get the same resources
+func restartContainer(rc *runningContainer) error {
+	opts := rc.opts
+	parent, writePipe := container.NewInitProcess(opts.Tty, opts.Name, opts.Cgroupns)
+	if parent == nil {
+		return fmt.Errorf("new init process error")
+	}
+	if err := parent.Start(); err != nil {
+		writePipe.Close()
+		closeLogFile(parent)
+		return fmt.Errorf("start container process error %v", err)
+	}
+	abort := func(err error) error {
+		writePipe.Close()
+		parent.Process.Kill()
+		parent.Wait()
+		closeLogFile(parent)
+		return err
+	}
+	if err := rc.cgroupManager.Apply(parent.Process.Pid); err != nil {
+		return abort(fmt.Errorf("apply cgroup %s error: %v", rc.cgroupManager.Path, err))
+	}
+	//the cgroup is kept,so the oom kills of the exited process are still in its counter
+	oomKillBase, err := rc.cgroupManager.OOMKillCount()
+	if err != nil {
+		log.Warnf("Get oom kill count of container %s error %v", opts.Name, err)
+	}
+	_, err = state.Update(opts.Name, func(containerInfo *container.ContainerInfo) error {
+		//the container may be stopped while the new process is started
+		if containerInfo.ManuallyStopped {
+			return fmt.Errorf("container %s is stopped", opts.Name)
+		}
+		containerInfo.Status = container.RUNNING
+		containerInfo.Pid = strconv.Itoa(parent.Process.Pid)
+		containerInfo.RestartCount++
+		//the oom kill belong to the exited process
+		containerInfo.OOMKilled = false
+		containerInfo.OOMKilledTime = ""
+		containerInfo.OOMKillBase = oomKillBase
+		return nil
+	})
+	if err != nil {
+		return abort(err)
+	}
+	sendInitCommand(opts.Command, writePipe)
+	rc.process = parent
+	rc.startedTime = time.Now()
+	return nil
+}
+
+// the log file opened for a detached container is closed once its process exit
+func closeLogFile(cmd *exec.Cmd) {
+	if file, ok := cmd.Stdout.(*os.File); ok && file != os.Stdout {
+		file.Close()
+	}
+}
+
+// waitContainerExit poll the info until the monitor record the exit of the container,
+// a monitor gone without recording it can not record it anymore,so the container is marked stopped
+func waitContainerExit(containerName string, monitorPid int, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		containerInfo, err := state.Get(containerName)
+		if err != nil {
+			return false, err
+		}
+		if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED &&
+			containerInfo.Status != container.RESTARTING {
+			return true, nil
+		}
+		if err := syscall.Kill(monitorPid, 0); err == syscall.ESRCH {
+			_, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+				if info.Status == container.RUNNING || info.Status == container.PAUSED ||
+					info.Status == container.RESTARTING {
+					return markStopped(info)
+				}
+				return nil
+			})
+			return err == nil, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(statePollInterval)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"docker-my/container"
+	"docker-my/state"
+	"docker-my/state/statetest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	var backoff restartBackoff
+	want := []time.Duration{
+		restartDelayMin,
+		2 * restartDelayMin,
+		4 * restartDelayMin,
+		8 * restartDelayMin,
+	}
+	for i, delay := range want {
+		if got := backoff.next(time.Second); got != delay {
+			t.Fatalf("restart %d delay %v,want %v", i, got, delay)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		backoff.next(time.Second)
+	}
+	if got := backoff.next(time.Second); got != restartDelayMax {
+		t.Fatalf("delay %v,want the max %v", got, restartDelayMax)
+	}
+	//a container running long enough start over from the min delay
+	if got := backoff.next(restartResetAfter); got != restartDelayMin {
+		t.Fatalf("delay after a long run %v,want %v", got, restartDelayMin)
+	}
+	if got := backoff.next(0); got != 2*restartDelayMin {
+		t.Fatalf("delay %v,want %v", got, 2*restartDelayMin)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		code   int
+		signal string
+	}{
+		{"exit 0", 0, ""},
+		{"exit 3", 3, ""},
+		//a process killed by a signal exit with 128+signal like the shell report
+		{"kill -KILL $$", 137, "SIGKILL"},
+		{"kill -TERM $$", 143, "SIGTERM"},
+	}
+	for _, test := range tests {
+		cmd := exec.Command("sh", "-c", test.script)
+		cmd.Run()
+		code, signal := exitStatus(cmd.ProcessState)
+		if code != test.code || signal != test.signal {
+			t.Errorf("%q exit %d %q,want %d %q", test.script, code, signal, test.code, test.signal)
+		}
+	}
+	if code, signal := exitStatus(nil); code != -1 || signal != "" {
+		t.Errorf("unknown exit %d %q,want -1", code, signal)
+	}
+}
+
+func TestMarkExited(t *testing.T) {
+	info := &container.ContainerInfo{Status: container.RUNNING, Pid: "100", MonitorPid: 99, ExclusiveCpus: "2-3"}
+	if err := markExited(info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.EXIT || info.Pid != " " || info.MonitorPid != 0 || info.ExclusiveCpus != "" {
+		t.Errorf("exited info %+v", info)
+	}
+}
+
+func TestRecordExit(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Id: "a1", Name: "once", Status: container.RUNNING, ExclusiveCpus: "2-3"})
+	createTestContainer(t, &container.ContainerInfo{Id: "b2", Name: "always", Status: container.RUNNING, ExclusiveCpus: "4",
+		RestartPolicy: container.RestartPolicy{Name: container.RestartAlways}})
+	cmd := exec.Command("sh", "-c", "exit 2")
+	cmd.Run()
+
+	if recordExit("once", cmd.ProcessState) {
+		t.Error("container without restart policy is restarted")
+	}
+	info, err := state.Get("once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.EXIT || info.ExitCode != 2 || info.ExclusiveCpus != "" {
+		t.Errorf("exited info %+v", info)
+	}
+
+	if !recordExit("always", cmd.ProcessState) {
+		t.Error("container with the always policy is not restarted")
+	}
+	if info, err = state.Get("always"); err != nil {
+		t.Fatal(err)
+	}
+	//the cpus stay pinned until the restart
+	if info.Status != container.RESTARTING || info.ExitCode != 2 || info.ExclusiveCpus != "4" {
+		t.Errorf("restarting info %+v", info)
+	}
+}
+
+func TestWaitContainerExitMonitorGone(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	//the pid of an exited process stand for the monitor killed without recording the exit
+	monitor := exec.Command("true")
+	if err := monitor.Run(); err != nil {
+		t.Fatal(err)
+	}
+	createTestContainer(t, &container.ContainerInfo{Id: "a1", Name: "web", Status: container.RUNNING,
+		Pid: "100", MonitorPid: monitor.Process.Pid, ExclusiveCpus: "2-3"})
+	exited, err := waitContainerExit("web", monitor.Process.Pid, time.Second)
+	if err != nil || !exited {
+		t.Fatalf("wait with the monitor gone = %v,%v,want exited", exited, err)
+	}
+	info, err := state.Get("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.STOP || info.Pid != " " || info.ExclusiveCpus != "" {
+		t.Errorf("info of the container losing its monitor %+v", info)
+	}
+}
+
+func TestWaitContainerExitTimeout(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Id: "a1", Name: "web", Status: container.RUNNING, MonitorPid: os.Getpid()})
+	start := time.Now()
+	exited, err := waitContainerExit("web", os.Getpid(), 2*statePollInterval)
+	if err != nil || exited {
+		t.Errorf("wait with the monitor alive = %v,%v,want timeout", exited, err)
+	}
+	if time.Since(start) < 2*statePollInterval {
+		t.Errorf("wait returned after %s", time.Since(start))
+	}
+	//an exited container is returned at once
+	if _, err := state.Update("web", markExited); err != nil {
+		t.Fatal(err)
+	}
+	if exited, err := waitContainerExit("web", os.Getpid(), 0); err != nil || !exited {
+		t.Errorf("wait an exited container = %v,%v", exited, err)
+	}
+}
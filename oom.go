@@ -0,0 +1,65 @@
+package main
+
+import (
+	"docker-my/cgroup"
+	"docker-my/container"
+	"docker-my/state"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// watchOOM record the oom kill of the container until its cgroup is removed
+func watchOOM(containerName string, cgroupManager *cgroup.CgroupManager) {
+	ch, err := cgroupManager.NotifyOOM()
+	if err != nil {
+		log.Warnf("Watch container %s oom error %v", containerName, err)
+		return
+	}
+	go func() {
+		for range ch {
+			log.Warnf("Container %s is killed by the oom killer", containerName)
+			containerInfo, err := state.Get(containerName)
+			if err != nil {
+				log.Errorf("Get container %s info error %v", containerName, err)
+				continue
+			}
+			markOOMKilled(containerInfo, time.Now())
+		}
+	}()
+}
+
+// refreshOOMStatus find the oom kill by the counter of the cgroup,
+// the event is missed when the container is recorded before its monitor or the monitor is gone,
+// the time of the kill is unknown then and left empty
+func refreshOOMStatus(containerInfo *container.ContainerInfo) {
+	if containerInfo.OOMKilled || containerInfo.CgroupPath == "" {
+		return
+	}
+	count, err := cgroup.NewCGroupManager(containerInfo.CgroupPath).OOMKillCount()
+	if err != nil || count <= containerInfo.OOMKillBase {
+		return
+	}
+	markOOMKilled(containerInfo, time.Time{})
+}
+
+// markOOMKilled record the oom kill,the zero killedTime means the time is unknown
+func markOOMKilled(containerInfo *container.ContainerInfo, killedTime time.Time) {
+	if containerInfo.OOMKilled && (containerInfo.OOMKilledTime != "" || killedTime.IsZero()) {
+		return
+	}
+	containerInfo.OOMKilled = true
+	if !killedTime.IsZero() {
+		containerInfo.OOMKilledTime = killedTime.Format("2006-01-02 15:04:05")
+	}
+	_, err := state.Update(containerInfo.Name, func(info *container.ContainerInfo) error {
+		info.OOMKilled = true
+		//only the watcher know the time,it fill the time even the kill is inferred before
+		if info.OOMKilledTime == "" {
+			info.OOMKilledTime = containerInfo.OOMKilledTime
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Update container %s info error %v", containerInfo.Name, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"docker-my/cgroup"
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"docker-my/state"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// pauseContainer ask the freeze and record the container paused under the state lock,so a concurrent
+// stop or unpause see the new status,the wait for the freezer to settle is done outside the lock
+func pauseContainer(containerName string) error {
+	info, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.RUNNING {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		if err := cgroup.NewCGroupManager(info.CgroupPath).SetFreezerState(subsystem.Frozen); err != nil {
+			return fmt.Errorf("freeze container %s error %v", containerName, err)
+		}
+		info.Status = container.PAUSED
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cgroupManager := cgroup.NewCGroupManager(info.CgroupPath)
+	err = cgroupManager.WaitFreezerState(subsystem.Frozen, func() error {
+		//the freeze is asked again only while the container is still paused
+		_, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+			if info.Status != container.PAUSED || info.ManuallyStopped {
+				return fmt.Errorf("container %s is not paused anymore", containerName)
+			}
+			return cgroupManager.SetFreezerState(subsystem.Frozen)
+		})
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+	//the container can not be frozen,it is thawed and running again
+	_, rollbackErr := state.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.PAUSED {
+			return nil
+		}
+		if err := cgroupManager.SetFreezerState(subsystem.Thawed); err != nil {
+			log.Errorf("Thaw container %s error %v", containerName, err)
+		}
+		info.Status = container.RUNNING
+		return nil
+	})
+	if rollbackErr != nil {
+		log.Errorf("Update container %s info error %v", containerName, rollbackErr)
+	}
+	return fmt.Errorf("freeze container %s error %v", containerName, err)
+}
+
+// unpauseContainer is like pauseContainer,the thaw is asked under the lock and waited outside it
+func unpauseContainer(containerName string) error {
+	info, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.PAUSED {
+			return fmt.Errorf("container %s is not paused", containerName)
+		}
+		if err := cgroup.NewCGroupManager(info.CgroupPath).SetFreezerState(subsystem.Thawed); err != nil {
+			return fmt.Errorf("thaw container %s error %v", containerName, err)
+		}
+		info.Status = container.RUNNING
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cgroup.NewCGroupManager(info.CgroupPath).WaitFreezerState(subsystem.Thawed, nil); err != nil {
+		return fmt.Errorf("thaw container %s error %v", containerName, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"docker-my/cgroup/subsystem/fakefs"
+	"docker-my/container"
+	"docker-my/state"
+	"docker-my/state/statetest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFreezerDir = "/sys/fs/cgroup/mydocker/web/"
+
+// settleFreezer report the asked freeze in cgroup.events like the kernel does once all the tasks are frozen
+func settleFreezer(t *testing.T, fs *fakefs.FileSystem, frozen string) {
+	t.Helper()
+	if err := fs.WriteFile(testFreezerDir+"cgroup.events", []byte("populated 1\nfrozen "+frozen), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFreeze(t *testing.T, fs *fakefs.FileSystem) string {
+	t.Helper()
+	content, err := fs.ReadFile(testFreezerDir + "cgroup.freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func TestPauseUnpause(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	fs := useFakeCgroupV2(t, "mydocker/web")
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "1", Status: container.RUNNING, CgroupPath: "mydocker/web"})
+
+	settleFreezer(t, fs, "1")
+	if err := pauseContainer("web"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if info, err := state.Get("web"); err != nil || info.Status != container.PAUSED {
+		t.Fatalf("paused container %+v,%v", info, err)
+	}
+	if got := readFreeze(t, fs); got != "1" {
+		t.Errorf("cgroup.freeze = %q, want 1", got)
+	}
+	if err := pauseContainer("web"); err == nil {
+		t.Error("pause a paused container succeeded")
+	}
+
+	settleFreezer(t, fs, "0")
+	if err := unpauseContainer("web"); err != nil {
+		t.Fatalf("unpause: %v", err)
+	}
+	if info, err := state.Get("web"); err != nil || info.Status != container.RUNNING {
+		t.Fatalf("unpaused container %+v,%v", info, err)
+	}
+	if got := readFreeze(t, fs); got != "0" {
+		t.Errorf("cgroup.freeze = %q, want 0", got)
+	}
+}
+
+func TestPauseWaitOutsideLock(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	fs := useFakeCgroupV2(t, "mydocker/web")
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "1", Status: container.RUNNING, CgroupPath: "mydocker/web"})
+
+	done := make(chan error, 1)
+	go func() { done <- pauseContainer("web") }()
+	//the freeze is not settled yet,the other commands can still read and update the container
+	deadline := time.Now().Add(time.Second)
+	for {
+		info, err := state.Update("web", func(info *container.ContainerInfo) error { return nil })
+		if err != nil {
+			t.Fatalf("update while pausing: %v", err)
+		}
+		if info.Status == container.PAUSED {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the container is not marked paused while freezing")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("pause returned %v before the freeze settled", err)
+	default:
+	}
+	settleFreezer(t, fs, "1")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("pause: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pause doesn't return after the freeze settled")
+	}
+}
+
+func TestPauseTimeoutRollback(t *testing.T) {
+	old := subsystem.FreezeTimeout
+	subsystem.FreezeTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { subsystem.FreezeTimeout = old })
+	statetest.UseTempInfoLocation(t)
+	fs := useFakeCgroupV2(t, "mydocker/web")
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "1", Status: container.RUNNING, CgroupPath: "mydocker/web"})
+
+	//the freeze never settle,the container is thawed and running again
+	if err := pauseContainer("web"); err == nil {
+		t.Fatal("pause with a freeze never settled succeeded")
+	}
+	if info, err := state.Get("web"); err != nil || info.Status != container.RUNNING {
+		t.Fatalf("container after a failed pause %+v,%v", info, err)
+	}
+	if got := readFreeze(t, fs); got != "0" {
+		t.Errorf("cgroup.freeze = %q, want 0", got)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"docker-my/cgroup"
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"docker-my/state"
+	"fmt"
+	"github.com/urfave/cli"
+	"math"
+)
+
+// resourceFlags is the resource limit flags shared by run and update
+var resourceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "m",
+		Usage: "memory limit,like 100m or 1g,-1 for unlimited",
+	},
+	cli.StringFlag{
+		Name:  "memory-swap",
+		Usage: "total limit of memory and swap,-1 for unlimited swap",
+	},
+	cli.StringFlag{
+		Name:  "memory-reservation",
+		Usage: "memory soft limit",
+	},
+	cli.StringFlag{
+		Name:  "memory-high",
+		Usage: "memory throttling limit,the container is slowed down above it instead of oom killed,only cgroup v2",
+	},
+	cli.Int64Flag{
+		Name:  "memory-swappiness",
+		Usage: "tune the swappiness of the container,from 0 to 100",
+	},
+	cli.StringFlag{
+		Name:  "cpushare",
+		Usage: "cpushare limit",
+	},
+	cli.StringFlag{
+		Name:  "cpuset",
+		Usage: "cpuset limit",
+	},
+	cli.StringFlag{
+		Name:  "cpuset-mems",
+		Usage: "numa memory nodes can be used,like 0-1",
+	},
+	cli.Float64Flag{
+		Name:  "cpus",
+		Usage: "number of cpus,like 1.5",
+	},
+	cli.Uint64Flag{
+		Name:  "cpu-period",
+		Usage: "cpu cfs period in microseconds",
+	},
+	cli.Int64Flag{
+		Name:  "cpu-quota",
+		Usage: "cpu cfs quota in microseconds,-1 for unlimited",
+	},
+	cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "max number of processes,-1 for unlimited",
+	},
+	cli.UintFlag{
+		Name:  "blkio-weight",
+		Usage: "block io relative weight,from 10 to 1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "blkio-weight-device",
+		Usage: "block io relative weight of a device,like /dev/sda:200",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-bps",
+		Usage: "limit read rate(bytes per second) from a device,like /dev/sda:1mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-bps",
+		Usage: "limit write rate(bytes per second) to a device,like /dev/sda:1mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-iops",
+		Usage: "limit read rate(io per second) from a device,like /dev/sda:1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-iops",
+		Usage: "limit write rate(io per second) to a device,like /dev/sda:1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "hugetlb",
+		Usage: "limit the huge pages usage of a page size,like 2MB:512m",
+	},
+	cli.StringSliceFlag{
+		Name:  "device",
+		Usage: "add a host device to the container,like /dev/fuse:rwm,it can not be changed by update",
+	},
+}
+
+// parseResourceConfig overwrite the resource config with the flags set in the command line,
+// the flags not set keep the value of the config
+func parseResourceConfig(context *cli.Context, res *subsystem.ResourceConfig) error {
+	memoryFlags := []struct {
+		flag  string
+		value *int64
+	}{
+		{"m", &res.MemoryLimit},
+		{"memory-swap", &res.MemorySwap},
+		{"memory-reservation", &res.MemoryReservation},
+		{"memory-high", &res.MemoryHigh},
+	}
+	for _, memory := range memoryFlags {
+		if !context.IsSet(memory.flag) {
+			continue
+		}
+		limit, err := subsystem.ParseMemoryLimit(context.String(memory.flag))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", memory.flag, err)
+		}
+		*memory.value = limit
+	}
+	if context.IsSet("memory-swappiness") {
+		swappiness := context.Int64("memory-swappiness")
+		res.MemorySwappiness = &swappiness
+	}
+	if context.IsSet("cpushare") {
+		res.CpuShare = context.String("cpushare")
+	}
+	if context.IsSet("cpuset") {
+		res.CpuSet = context.String("cpuset")
+	}
+	if context.IsSet("cpuset-mems") {
+		res.CpuSetMems = context.String("cpuset-mems")
+	}
+	if context.IsSet("cpus") {
+		if context.IsSet("cpu-period") || context.IsSet("cpu-quota") {
+			return fmt.Errorf("cpus and cpu-period/cpu-quota can not both provided")
+		}
+		cpus := context.Float64("cpus")
+		if cpus <= 0 {
+			return fmt.Errorf("invalid cpus %v", cpus)
+		}
+		//--cpus 1.5 is the quota 150000 of the period 100000,it is rounded since 0.29*100000 is 28999.999...
+		res.CpuPeriod = subsystem.DefaultCpuPeriod
+		res.CpuQuota = int64(math.Round(cpus * float64(subsystem.DefaultCpuPeriod)))
+	}
+	if context.IsSet("cpu-period") {
+		res.CpuPeriod = context.Uint64("cpu-period")
+	}
+	if context.IsSet("cpu-quota") {
+		res.CpuQuota = context.Int64("cpu-quota")
+	}
+	if context.IsSet("pids-limit") {
+		res.PidsLimit = context.Int64("pids-limit")
+	}
+	if context.IsSet("blkio-weight") {
+		weight := context.Uint("blkio-weight")
+		if weight < 10 || weight > 1000 {
+			return fmt.Errorf("invalid blkio weight %d,the range is from 10 to 1000", weight)
+		}
+		res.BlkioWeight = uint16(weight)
+	}
+	if context.IsSet("blkio-weight-device") {
+		res.BlkioWeightDevice = nil
+		for _, spec := range context.StringSlice("blkio-weight-device") {
+			weightDevice, err := subsystem.ParseWeightDevice(spec)
+			if err != nil {
+				return err
+			}
+			res.BlkioWeightDevice = append(res.BlkioWeightDevice, weightDevice)
+		}
+	}
+	throttles := []struct {
+		flag     string
+		devices  *[]*subsystem.ThrottleDevice
+		withUnit bool
+	}{
+		{"device-read-bps", &res.BlkioDeviceReadBps, true},
+		{"device-write-bps", &res.BlkioDeviceWriteBps, true},
+		{"device-read-iops", &res.BlkioDeviceReadIOps, false},
+		{"device-write-iops", &res.BlkioDeviceWriteIOps, false},
+	}
+	for _, throttle := range throttles {
+		if !context.IsSet(throttle.flag) {
+			continue
+		}
+		*throttle.devices = nil
+		for _, spec := range context.StringSlice(throttle.flag) {
+			throttleDevice, err := subsystem.ParseThrottleDevice(spec, throttle.withUnit)
+			if err != nil {
+				return err
+			}
+			*throttle.devices = append(*throttle.devices, throttleDevice)
+		}
+	}
+	if context.IsSet("hugetlb") {
+		res.HugetlbLimit = nil
+		for _, spec := range context.StringSlice("hugetlb") {
+			hugetlb, err := subsystem.ParseHugetlbLimit(spec)
+			if err != nil {
+				return err
+			}
+			res.HugetlbLimit = append(res.HugetlbLimit, hugetlb)
+		}
+	}
+	if context.IsSet("device") {
+		res.Devices = nil
+		for _, spec := range context.StringSlice("device") {
+			device, err := subsystem.ParseDevice(spec)
+			if err != nil {
+				return err
+			}
+			res.Devices = append(res.Devices, device)
+		}
+	}
+	return res.Validate()
+}
+
+// updateContainer write the new resource limit to the cgroup of a running container,
+// and save it into the config of the container
+func updateContainer(containerName string, context *cli.Context) error {
+	//the device node is created in the rootfs before the container start,it can not be added later
+	if context.IsSet("device") {
+		return fmt.Errorf("device can only be added when the container is created")
+	}
+	if context.IsSet("cpuset") {
+		//the allocation lock is held until the new cpuset is recorded,the state lock is taken
+		//after it just like run,and the check list the containers so it can not be done under the state lock
+		allocation, err := reserveCpuset(context.String("cpuset"), containerName)
+		if err != nil {
+			return err
+		}
+		defer allocation.Release()
+	}
+	//the status is checked and the cgroup is set under the state lock,so a stop
+	//or rm can not happen between them and the limits are never written to a gone cgroup
+	_, err := state.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.RUNNING && info.Status != container.PAUSED {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		if context.IsSet("cpuset") && info.ExclusiveCpus != "" {
+			return fmt.Errorf("container %s has the exclusive cpus %s,its cpuset can not be changed", containerName, info.ExclusiveCpus)
+		}
+		res := &subsystem.ResourceConfig{}
+		if info.Resources != nil {
+			*res = *info.Resources
+		}
+		if err := parseResourceConfig(context, res); err != nil {
+			return err
+		}
+		if err := cgroup.NewCGroupManager(info.CgroupPath).Set(res); err != nil {
+			return fmt.Errorf("update container %s cgroup error %v", containerName, err)
+		}
+		info.Resources = res
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"flag"
+	"fmt"
+	"github.com/urfave/cli"
+	"runtime"
+	"testing"
+)
+
+// parseTestResource parse the resource flags of the args like the run command does
+func parseTestResource(args ...string) (*subsystem.ResourceConfig, error) {
+	set := flag.NewFlagSet("run", flag.ContinueOnError)
+	for _, f := range resourceFlags {
+		f.Apply(set)
+	}
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+	res := &subsystem.ResourceConfig{}
+	if err := parseResourceConfig(cli.NewContext(nil, set, nil), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func TestParseResourceConfigCpus(t *testing.T) {
+	tests := []struct {
+		cpus   string
+		period uint64
+		quota  int64
+	}{
+		{"1", 100000, 100000},
+		{"0.5", 100000, 50000},
+		{"0.29", 100000, 29000},
+		{"0.01", 100000, 1000},
+	}
+	for _, test := range tests {
+		res, err := parseTestResource("--cpus", test.cpus)
+		if err != nil {
+			t.Errorf("--cpus %s: %v", test.cpus, err)
+			continue
+		}
+		if res.CpuPeriod != test.period || res.CpuQuota != test.quota {
+			t.Errorf("--cpus %s = period %d quota %d,want %d %d", test.cpus, res.CpuPeriod, res.CpuQuota, test.period, test.quota)
+		}
+	}
+	res, err := parseTestResource("--cpu-period", "50000", "--cpu-quota", "25000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CpuPeriod != 50000 || res.CpuQuota != 25000 {
+		t.Errorf("period %d quota %d,want 50000 25000", res.CpuPeriod, res.CpuQuota)
+	}
+	for _, args := range [][]string{
+		{"--cpus", "0"},
+		{"--cpus", "-1"},
+		{"--cpus", "0.001"},
+		{"--cpus", fmt.Sprint(runtime.NumCPU() + 1)},
+		{"--cpus", "0.5", "--cpu-quota", "50000"},
+		{"--cpu-period", "999"},
+	} {
+		if _, err := parseTestResource(args...); err == nil {
+			t.Errorf("%q: want error", args)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package state
+
+import (
+	"docker-my/container"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// CurrentVersion is the schema version of the container info written by this runtime,
+// the info of an older version is migrated when it is read
+const CurrentVersion = 1
+
+// the lock file in the dir of every container,it is flocked around every read and write
+const lockFileName = ".lock"
+
+var (
+	// ErrExist is returned when a container with the same name is already recorded
+	ErrExist = errors.New("container already exists")
+	// ErrAmbiguous is returned when an id prefix match more than one container
+	ErrAmbiguous = errors.New("container reference is ambiguous")
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// migrations[i] upgrade the info from the version i to i+1
+var migrations = []func(info *container.ContainerInfo, name string){
+	// the info written before the version field may miss the name and the id
+	func(info *container.ContainerInfo, name string) {
+		if info.Name == "" {
+			info.Name = name
+		}
+		if info.Id == "" {
+			info.Id = name
+		}
+	},
+}
+
+// Dir return the dir the info,the log and the usage of the container are stored in
+func Dir(name string) string {
+	return fmt.Sprintf(container.DefaultInfoLocation, name)
+}
+
+func configPath(name string) string {
+	return filepath.Join(Dir(name), container.ConfigName)
+}
+
+// hold the flock of one container,the lock file is only made with the dir by Reserve or Create,
+// so taking the lock never bring back the dir of a container being removed
+func lock(name string, how int) (*os.File, error) {
+	lockPath := filepath.Join(Dir(name), lockFileName)
+	lockFile, err := os.OpenFile(lockPath, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		//the container recorded by an old runtime has the config but no lock file
+		if _, statErr := os.Stat(configPath(name)); statErr == nil {
+			lockFile, err = os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("container %s does not exist: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("open lock of container %s error %v", name, err)
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), how); err != nil {
+		lockFile.Close()
+		return nil, fmt.Errorf("lock container %s error %v", name, err)
+	}
+	//the dir may be removed while waiting for the lock,the lock of a removed file protect nothing
+	lockInfo, err := lockFile.Stat()
+	pathInfo, statErr := os.Stat(lockPath)
+	if err != nil || statErr != nil || !os.SameFile(lockInfo, pathInfo) {
+		unlock(lockFile)
+		return nil, fmt.Errorf("container %s does not exist: %w", name, os.ErrNotExist)
+	}
+	return lockFile, nil
+}
+
+// createLockFile make the lock file in the dir just made for the container
+func createLockFile(name string) error {
+	lockFile, err := os.OpenFile(filepath.Join(Dir(name), lockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("create lock of container %s error %v", name, err)
+	}
+	return lockFile.Close()
+}
+
+func unlock(lockFile *os.File) {
+	syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+	lockFile.Close()
+}
+
+func read(name string) (*container.ContainerInfo, error) {
+	content, err := os.ReadFile(configPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("container %s does not exist: %w", name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	var info container.ContainerInfo
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, fmt.Errorf("unmarshal info of container %s error %v", name, err)
+	}
+	if info.Version > CurrentVersion {
+		return nil, fmt.Errorf("info of container %s has version %d,newer than the supported %d", name, info.Version, CurrentVersion)
+	}
+	for info.Version < CurrentVersion {
+		migrations[info.Version](&info, name)
+		info.Version++
+	}
+	return &info, nil
+}
+
+// write the info to a temp file and rename it over the config,
+// a reader never see a half written config even if the runtime crash
+func write(info *container.ContainerInfo) error {
+	info.Version = CurrentVersion
+	jsonBytes, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(configPath(info.Name), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("write config of container %s error %v", info.Name, err)
+	}
+	return nil
+}
+
+// WriteFileAtomic write the data to a temp file in the same dir and rename it over the file,
+// the file is either the old content or the new one
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(perm); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), filename); err != nil {
+		return err
+	}
+	//make the rename durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Reserve take the name for a new container before anything is written to its dir,
+// the dir of a container is its entry in the name registry,so the mkdir fail with
+// ErrExist when another container already use the name
+func Reserve(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid container name %s", name)
+	}
+	parent := strings.TrimSuffix(Dir(""), "/")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("mkdir %s error %v", parent, err)
+	}
+	if err := os.Mkdir(Dir(name), 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("container name %s is already in use: %w", name, ErrExist)
+		}
+		return fmt.Errorf("mkdir for container %s error %v", name, err)
+	}
+	if err := createLockFile(name); err != nil {
+		os.RemoveAll(Dir(name))
+		return err
+	}
+	return nil
+}
+
+// Release give back a reserved name whose container is never created
+func Release(name string) error {
+	lockFile, err := lock(name, syscall.LOCK_EX)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer unlock(lockFile)
+	if _, err := os.Stat(configPath(name)); err == nil {
+		return fmt.Errorf("container %s: %w", name, ErrExist)
+	}
+	return os.RemoveAll(Dir(name))
+}
+
+// Resolve return the name of the container a reference point to,the reference is
+// a name,a full id or a prefix of the id which match only one container
+func Resolve(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("empty container reference")
+	}
+	//the name is tried first without reading all the containers
+	if namePattern.MatchString(ref) {
+		if _, err := os.Stat(configPath(ref)); err == nil {
+			return ref, nil
+		}
+	}
+	containers, err := List()
+	if err != nil {
+		return "", err
+	}
+	var matches []*container.ContainerInfo
+	for _, info := range containers {
+		if info.Id == ref {
+			return info.Name, nil
+		}
+		if strings.HasPrefix(info.Id, ref) {
+			matches = append(matches, info)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no such container %s: %w", ref, os.ErrNotExist)
+	case 1:
+		return matches[0].Name, nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, info := range matches {
+		names = append(names, info.Name)
+	}
+	return "", fmt.Errorf("%s match %d containers (%s): %w", ref, len(matches), strings.Join(names, ", "), ErrAmbiguous)
+}
+
+// Create record a new container,ErrExist is returned when the name is taken
+// by a recorded container,the dir may already be made by Reserve
+func Create(info *container.ContainerInfo) error {
+	if err := os.MkdirAll(Dir(info.Name), 0755); err != nil {
+		return fmt.Errorf("mkdir for container %s error %v", info.Name, err)
+	}
+	if err := createLockFile(info.Name); err != nil {
+		return err
+	}
+	lockFile, err := lock(info.Name, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock(lockFile)
+	if _, err := os.Stat(configPath(info.Name)); err == nil {
+		return fmt.Errorf("container %s: %w", info.Name, ErrExist)
+	}
+	return write(info)
+}
+
+// Get read the info of a container
+func Get(name string) (*container.ContainerInfo, error) {
+	lockFile, err := lock(name, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(lockFile)
+	return read(name)
+}
+
+// List read the info of all the recorded containers,a broken one is skipped
+func List() ([]*container.ContainerInfo, error) {
+	dir := strings.TrimSuffix(Dir(""), "/")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read dir %s error %v", dir, err)
+	}
+	var containers []*container.ContainerInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := Get(entry.Name())
+		if err != nil {
+			//the dir of a container being created has no config yet
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Errorf("Get container %s info error %v", entry.Name(), err)
+			}
+			continue
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+// Update change the info of a container while holding its lock,so the concurrent
+// updates never overwrite each other,the info is not written when fn return an error
+func Update(name string, fn func(info *container.ContainerInfo) error) (*container.ContainerInfo, error) {
+	lockFile, err := lock(name, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(lockFile)
+	info, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(info); err != nil {
+		return nil, err
+	}
+	if err := write(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Remove delete all the stored data of a container,fn is called with the lock held
+// before the removal and can refuse it by returning an error,the dir is removed under
+// the lock and the waiters find it gone when they get the lock
+func Remove(name string, fn func(info *container.ContainerInfo) error) error {
+	lockFile, err := lock(name, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock(lockFile)
+	info, err := read(name)
+	if err != nil {
+		return err
+	}
+	if fn != nil {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(Dir(name)); err != nil {
+		return fmt.Errorf("remove dir of container %s error %v", name, err)
+	}
+	return nil
+}
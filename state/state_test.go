@@ -0,0 +1,226 @@
+package state
+
+import (
+	"docker-my/container"
+	"docker-my/state/statetest"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeRawInfo write the config as an old runtime did,without the lock and the version
+func writeRawInfo(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath(name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUnversionedInfo(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	writeRawInfo(t, "web", `{"pid":"100","command":"top","status":"running"}`)
+
+	info, err := Get("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != CurrentVersion || info.Name != "web" || info.Id != "web" {
+		t.Fatalf("migrated info version %d name %q id %q", info.Version, info.Name, info.Id)
+	}
+	if info.Pid != "100" || info.Command != "top" || info.Status != container.RUNNING {
+		t.Fatalf("migration lost the fields %+v", info)
+	}
+
+	//the migrated info is written back with the current version by the next update
+	if _, err := Update("web", func(info *container.ContainerInfo) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(configPath("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRawInfo(t, "copy", string(content))
+	copied, err := Get("copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Version != CurrentVersion || copied.Name != "web" {
+		t.Fatalf("written info version %d name %q", copied.Version, copied.Name)
+	}
+}
+
+func TestMigrateKeepRecordedId(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	writeRawInfo(t, "web", `{"id":"0123456789","name":"web"}`)
+	info, err := Get("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "0123456789" {
+		t.Fatalf("id %q,want the recorded one", info.Id)
+	}
+}
+
+func TestNewerVersionRejected(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	writeRawInfo(t, "web", `{"version":99,"name":"web","id":"abc"}`)
+	if _, err := Get("web"); err == nil {
+		t.Fatal("want error for the info of a newer version")
+	}
+	if _, err := Update("web", func(info *container.ContainerInfo) error { return nil }); err == nil {
+		t.Fatal("the info of a newer version should not be rewritten")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	for _, info := range []*container.ContainerInfo{
+		{Name: "web", Id: "ab12cd34"},
+		{Name: "db", Id: "ab12ef56"},
+		{Name: "cache", Id: "9f00aa11"},
+		//a name look like the id prefix of another container
+		{Name: "9f00", Id: "77665544"},
+	} {
+		if err := Create(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//a reserved name without config is not a container
+	if err := Reserve("pending"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"web", "web"},
+		{"ab12cd34", "web"},
+		{"ab12c", "web"},
+		{"ab12e", "db"},
+		{"9f00aa11", "cache"},
+		{"9f00a", "cache"},
+		//the name win over the id prefix
+		{"9f00", "9f00"},
+		{"7766", "9f00"},
+	}
+	for _, test := range tests {
+		got, err := Resolve(test.ref)
+		if err != nil {
+			t.Errorf("%q: %v", test.ref, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q = %q,want %q", test.ref, got, test.want)
+		}
+	}
+
+	if _, err := Resolve("ab12"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("ab12: error %v,want ErrAmbiguous", err)
+	}
+	for _, ref := range []string{"ffff", "pending", "webx"} {
+		if _, err := Resolve(ref); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%q: error %v,want not exist", ref, err)
+		}
+	}
+	if _, err := Resolve(""); err == nil {
+		t.Error("empty reference: want error")
+	}
+}
+
+func TestLockRemovedContainer(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	if err := Create(&container.ContainerInfo{Id: "a1", Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	//the lock is held by a rm while an update wait for it
+	lockFile, err := lock("web", syscall.LOCK_EX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := make(chan error)
+	go func() {
+		_, err := Update("web", func(info *container.ContainerInfo) error {
+			info.Status = container.STOP
+			return nil
+		})
+		updated <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := os.RemoveAll(Dir("web")); err != nil {
+		t.Fatal(err)
+	}
+	//a new container take the name before the waiter get the lock of the removed one
+	if err := Create(&container.ContainerInfo{Id: "b2", Name: "web", Status: container.RUNNING}); err != nil {
+		t.Fatal(err)
+	}
+	unlock(lockFile)
+	if err := <-updated; !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("update with the lock of a removed container error %v,want not exist", err)
+	}
+	info, err := Get("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "b2" || info.Status != container.RUNNING {
+		t.Errorf("new container is changed by the stale lock %+v", info)
+	}
+	if err := Remove("web", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Dir("web")); !os.IsNotExist(err) {
+		t.Errorf("dir of the removed container is left,stat error %v", err)
+	}
+}
+
+func TestLockWithoutLockFile(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	//a dir without the config and the lock is not a container
+	if err := os.MkdirAll(Dir("ghost"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lock("ghost", syscall.LOCK_SH); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock a dir without config error %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir("ghost"), lockFileName)); !os.IsNotExist(err) {
+		t.Errorf("lock file is created,stat error %v", err)
+	}
+	if _, err := lock("missing", syscall.LOCK_SH); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock a missing container error %v", err)
+	}
+}
+
+func TestReserveRelease(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	if err := Reserve("web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reserve("web"); !errors.Is(err, ErrExist) {
+		t.Errorf("reserve twice error %v", err)
+	}
+	if err := Release("web"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Dir("web")); !os.IsNotExist(err) {
+		t.Errorf("released dir is left,stat error %v", err)
+	}
+	if err := Release("web"); err != nil {
+		t.Errorf("release twice: %v", err)
+	}
+	//the name of a created container is not released
+	if err := Reserve("web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(&container.ContainerInfo{Id: "a1", Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release("web"); !errors.Is(err, ErrExist) {
+		t.Errorf("release a created container error %v", err)
+	}
+}
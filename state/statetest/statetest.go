@@ -0,0 +1,16 @@
+// Package statetest keep the recorded containers of a test in a temp dir,it is shared by the
+// tests of the state package and the commands
+package statetest
+
+import (
+	"docker-my/container"
+	"path/filepath"
+	"testing"
+)
+
+// UseTempInfoLocation keep the container info in a temp dir until the test end
+func UseTempInfoLocation(t testing.TB) {
+	old := container.DefaultInfoLocation
+	container.DefaultInfoLocation = filepath.Join(t.TempDir(), "%s") + "/"
+	t.Cleanup(func() { container.DefaultInfoLocation = old })
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"docker-my/cgroup"
+	"docker-my/cgroup/subsystem"
+	"docker-my/container"
+	"docker-my/state"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// the interval to sample the usage of the containers
+const statsInterval = time.Second
+
+// USER_HZ,the unit of the cpu time in /proc/stat
+const clockTicksPerSecond = 100
+
+// containerStatsEntry is one row of the stats output
+type containerStatsEntry struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	CpuPercent    float64 `json:"cpuPercent"`
+	MemoryUsage   uint64  `json:"memoryUsage"`
+	MemoryLimit   uint64  `json:"memoryLimit"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	BlockRead     uint64  `json:"blockRead"`
+	BlockWrite    uint64  `json:"blockWrite"`
+	Pids          uint64  `json:"pids"`
+	// the huge pages usage keyed by the page size
+	HugetlbUsage map[string]uint64 `json:"hugetlbUsage,omitempty"`
+	// the pressure stall information,only provided by cgroup v2
+	CpuPressure    *subsystem.PressureStats `json:"cpuPressure,omitempty"`
+	MemoryPressure *subsystem.PressureStats `json:"memoryPressure,omitempty"`
+	IoPressure     *subsystem.PressureStats `json:"ioPressure,omitempty"`
+}
+
+func statsContainers(names []string, noStream bool, format string) error {
+	hostMemory, err := getHostMemory()
+	if err != nil {
+		return err
+	}
+	prevSystem, _, err := getSystemCpuUsage()
+	if err != nil {
+		return err
+	}
+	containers, err := getStatsTargets(names)
+	if err != nil {
+		return err
+	}
+	prevStats := readContainersStats(containers)
+	for {
+		time.Sleep(statsInterval)
+		system, onlineCpus, err := getSystemCpuUsage()
+		if err != nil {
+			return err
+		}
+		//the containers may come and go when all the running containers are watched
+		if containers, err = getStatsTargets(names); err != nil {
+			return err
+		}
+		stats := readContainersStats(containers)
+		var entries []*containerStatsEntry
+		for _, item := range containers {
+			current, ok := stats[item.Id]
+			if !ok {
+				continue
+			}
+			entry := &containerStatsEntry{
+				ID:             item.Id,
+				Name:           item.Name,
+				MemoryUsage:    current.Memory.Usage,
+				MemoryLimit:    current.Memory.Limit,
+				BlockRead:      current.Blkio.ReadBytes,
+				BlockWrite:     current.Blkio.WriteBytes,
+				Pids:           current.Pids.Current,
+				CpuPressure:    current.Cpu.Pressure,
+				MemoryPressure: current.Memory.Pressure,
+				IoPressure:     current.Blkio.Pressure,
+			}
+			for pageSize, hugetlb := range current.Hugetlb {
+				if entry.HugetlbUsage == nil {
+					entry.HugetlbUsage = make(map[string]uint64)
+				}
+				entry.HugetlbUsage[pageSize] = hugetlb.Usage
+			}
+			//an unlimited cgroup is limited by the host memory
+			if entry.MemoryLimit == 0 || entry.MemoryLimit > hostMemory {
+				entry.MemoryLimit = hostMemory
+			}
+			entry.MemoryPercent = float64(entry.MemoryUsage) / float64(entry.MemoryLimit) * 100
+			if prev, ok := prevStats[item.Id]; ok && system > prevSystem && current.Cpu.UsageNanos > prev.Cpu.UsageNanos {
+				cpuDelta := float64(current.Cpu.UsageNanos - prev.Cpu.UsageNanos)
+				entry.CpuPercent = cpuDelta / float64(system-prevSystem) * float64(onlineCpus) * 100
+			}
+			entries = append(entries, entry)
+		}
+		if err := printContainersStats(entries, format, !noStream); err != nil {
+			return err
+		}
+		if noStream {
+			return nil
+		}
+		prevSystem, prevStats = system, stats
+	}
+}
+
+// return the containers to watch,all the running containers when no name is given
+func getStatsTargets(names []string) ([]*container.ContainerInfo, error) {
+	if len(names) == 0 {
+		allContainers, err := state.List()
+		if err != nil {
+			return nil, err
+		}
+		var containers []*container.ContainerInfo
+		for _, item := range allContainers {
+			if item.Status == container.RUNNING || item.Status == container.PAUSED {
+				containers = append(containers, item)
+			}
+		}
+		return containers, nil
+	}
+	var containers []*container.ContainerInfo
+	for _, ref := range names {
+		name, err := state.Resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		containerInfo, err := state.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("get container %s info error %v", name, err)
+		}
+		if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+			return nil, fmt.Errorf("container %s is not running", name)
+		}
+		containers = append(containers, containerInfo)
+	}
+	return containers, nil
+}
+
+// read the stats from the cgroup of every container,keyed by the container id
+func readContainersStats(containers []*container.ContainerInfo) map[string]*subsystem.Stats {
+	stats := make(map[string]*subsystem.Stats)
+	for _, item := range containers {
+		if item.CgroupPath == "" {
+			continue
+		}
+		//the stats readable are still shown when some subsystem fail
+		containerStats, err := cgroup.NewCGroupManager(item.CgroupPath).GetStats()
+		if err != nil {
+			log.Warnf("Get container %s stats error %v", item.Name, err)
+		}
+		stats[item.Id] = containerStats
+	}
+	return stats
+}
+
+func printContainersStats(entries []*containerStatsEntry, format string, clear bool) error {
+	if format == "json" {
+		for _, entry := range entries {
+			jsonBytes, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, string(jsonBytes))
+		}
+		return nil
+	}
+	if clear {
+		//clear the screen and move the cursor to the top left
+		fmt.Fprint(os.Stdout, "\033[2J\033[H")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "CONTAINER ID\tNAME\tCPU %%\tMEM USAGE / LIMIT\tMEM %%\tBLOCK I/O\tPIDS\tHUGETLB\tPSI CPU / MEM / IO\n")
+	for _, entry := range entries {
+		var hugetlbUsage uint64
+		for _, usage := range entry.HugetlbUsage {
+			hugetlbUsage += usage
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%d\t%s\t%s / %s / %s\n",
+			container.ShortID(entry.ID),
+			entry.Name,
+			entry.CpuPercent,
+			formatBytes(entry.MemoryUsage),
+			formatBytes(entry.MemoryLimit),
+			entry.MemoryPercent,
+			formatBytes(entry.BlockRead),
+			formatBytes(entry.BlockWrite),
+			entry.Pids,
+			formatBytes(hugetlbUsage),
+			formatPressure(entry.CpuPressure),
+			formatPressure(entry.MemoryPressure),
+			formatPressure(entry.IoPressure))
+	}
+	return w.Flush()
+}
+
+// format the share of the time some tasks stalled in the last 10 seconds
+func formatPressure(pressure *subsystem.PressureStats) string {
+	if pressure == nil {
+		return "--"
+	}
+	return fmt.Sprintf("%.2f%%", pressure.Some.Avg10)
+}
+
+// get the total cpu time of the host in nanoseconds and the number of the online cpus
+func getSystemCpuUsage() (uint64, int, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var total uint64
+	onlineCpus := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		//the "cpu" line is the sum of all the "cpuN" lines
+		if fields[0] != "cpu" {
+			onlineCpus++
+			continue
+		}
+		for _, field := range fields[1:] {
+			ticks, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("parse /proc/stat %s error %v", field, err)
+			}
+			total += ticks
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return total * uint64(time.Second) / clockTicksPerSecond, onlineCpus, nil
+}
+
+// get the MemTotal of the host in bytes
+func getHostMemory() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		//MemTotal:       16318408 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("parse MemTotal %s error %v", fields[1], err)
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
+
+// format the bytes with the binary units,like 1.5MiB
+func formatBytes(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.2f%s", value, units[i])
+}
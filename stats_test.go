@@ -0,0 +1,68 @@
+package main
+
+import (
+	"docker-my/cgroup/subsystem"
+	"docker-my/cgroup/subsystem/fakefs"
+	"docker-my/container"
+	"testing"
+)
+
+// nopDeviceFilter accept the device rules without attaching a BPF program
+type nopDeviceFilter struct{}
+
+func (nopDeviceFilter) Attach(cgroupDir string, rules []*subsystem.DeviceRule) error {
+	return nil
+}
+
+// useFakeCgroupV2 put the cgroup of the tests on an in-memory unified hierarchy
+func useFakeCgroupV2(t *testing.T, cgroupPaths ...string) *fakefs.FileSystem {
+	fs := fakefs.New(map[string]string{
+		"cgroup.controllers":     "cpu memory pids",
+		"cgroup.subtree_control": "",
+		"cgroup.procs":           "",
+		"cgroup.freeze":          "0",
+		"cgroup.events":          "populated 1\nfrozen 0",
+		"memory.current":         "4096",
+		"memory.max":             "max",
+		"memory.events":          "oom_kill 0",
+		"cpu.stat":               "usage_usec 5",
+		"pids.current":           "1",
+		"pids.max":               "max",
+	})
+	for _, cgroupPath := range append([]string{""}, cgroupPaths...) {
+		if err := fs.MkdirAll("/sys/fs/cgroup/"+cgroupPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldFS, oldMountInfo, oldFilter := subsystem.FS, subsystem.OpenMountInfo, subsystem.DeviceFilter
+	subsystem.FS = fs
+	subsystem.OpenMountInfo = fakefs.MountInfo("25 30 0:23 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw\n")
+	subsystem.DeviceFilter = nopDeviceFilter{}
+	t.Cleanup(func() {
+		subsystem.FS, subsystem.OpenMountInfo, subsystem.DeviceFilter = oldFS, oldMountInfo, oldFilter
+	})
+	return fs
+}
+
+func TestReadContainersStatsMissingFile(t *testing.T) {
+	fs := useFakeCgroupV2(t, "mydocker/a", "mydocker/b")
+	//the memory controller file of b is gone,its other stats are still shown
+	if err := fs.Remove("/sys/fs/cgroup/mydocker/b/memory.current"); err != nil {
+		t.Fatal(err)
+	}
+	containers := []*container.ContainerInfo{
+		{Id: "a", Name: "a", CgroupPath: "mydocker/a"},
+		{Id: "b", Name: "b", CgroupPath: "mydocker/b"},
+		{Id: "c", Name: "c"},
+	}
+	stats := readContainersStats(containers)
+	if len(stats) != 2 {
+		t.Fatalf("stats of %d containers,want 2", len(stats))
+	}
+	if stats["a"].Memory.Usage != 4096 || stats["a"].Pids.Current != 1 {
+		t.Errorf("stats of a memory %+v,pids %+v", stats["a"].Memory, stats["a"].Pids)
+	}
+	if stats["b"].Memory.Usage != 0 || stats["b"].Pids.Current != 1 || stats["b"].Cpu.UsageNanos != 5000 {
+		t.Errorf("partial stats of b memory %+v,pids %+v,cpu %+v", stats["b"].Memory, stats["b"].Pids, stats["b"].Cpu)
+	}
+}
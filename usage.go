@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"docker-my/cgroup"
+	"docker-my/state"
+	"encoding/binary"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// the usage history is kept in the dir of the container,so it lives until rm
+const usageFileName = "usage.bin"
+
+// DefaultUsageInterval is the interval the usage of a container is sampled at
+const DefaultUsageInterval = 10 * time.Second
+
+// the magic of the usage file,followed by the version and the sample interval
+var usageMagic = [4]byte{'M', 'D', 'U', 'S'}
+
+const usageVersion uint32 = 1
+
+type usageHeader struct {
+	Magic   [4]byte
+	Version uint32
+	// the sample interval in nanoseconds
+	Interval int64
+}
+
+// usageRecord is one sample of the cgroup counters,every record has the same size
+// and is appended in little endian
+type usageRecord struct {
+	// unix time in nanoseconds
+	Time       int64
+	CpuNanos   uint64
+	Memory     uint64
+	MemoryPeak uint64
+	Pids       uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+func usageFilePath(containerName string) string {
+	return state.Dir(containerName) + usageFileName
+}
+
+// usageRecorder append the samples of one container to its usage file
+type usageRecorder struct {
+	file     *os.File
+	manager  *cgroup.CgroupManager
+	interval time.Duration
+}
+
+func newUsageRecorder(containerName, cgroupPath string, interval time.Duration) (*usageRecorder, error) {
+	filePath := usageFilePath(containerName)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open usage file %s error %v", filePath, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	//the header is written once,a restarted recorder keep appending to the same file
+	if info.Size() == 0 {
+		header := usageHeader{Magic: usageMagic, Version: usageVersion, Interval: int64(interval)}
+		if err := binary.Write(file, binary.LittleEndian, &header); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("write usage header error %v", err)
+		}
+	}
+	return &usageRecorder{
+		file:     file,
+		manager:  cgroup.NewCGroupManager(cgroupPath),
+		interval: interval,
+	}, nil
+}
+
+// sample read the cgroup counters and append them as one record,the counters of
+// a subsystem failed to read are recorded as zero and the error is returned after the write
+func (r *usageRecorder) sample() error {
+	stats, statsErr := r.manager.GetStats()
+	record := usageRecord{
+		Time:       time.Now().UnixNano(),
+		CpuNanos:   stats.Cpu.UsageNanos,
+		Memory:     stats.Memory.Usage,
+		MemoryPeak: stats.Memory.MaxUsage,
+		Pids:       stats.Pids.Current,
+		ReadBytes:  stats.Blkio.ReadBytes,
+		WriteBytes: stats.Blkio.WriteBytes,
+	}
+	if err := binary.Write(r.file, binary.LittleEndian, &record); err != nil {
+		return err
+	}
+	return statsErr
+}
+
+// run sample at the interval until done is closed,
+// the last sample is taken before returning so the final counters are kept
+func (r *usageRecorder) run(done <-chan struct{}) {
+	defer func() {
+		if err := r.file.Close(); err != nil {
+			log.Warnf("Close usage file error %v", err)
+		}
+	}()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	if err := r.sample(); err != nil {
+		log.Warnf("Sample usage error %v", err)
+	}
+	for {
+		select {
+		case <-done:
+			if err := r.sample(); err != nil {
+				log.Warnf("Sample usage error %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := r.sample(); err != nil {
+				log.Warnf("Sample usage error %v", err)
+			}
+		}
+	}
+}
+
+// recordUsage sample the usage of a container in a goroutine,
+// the returned stop take the last sample and wait for the recorder to finish
+func recordUsage(containerName, cgroupPath string, interval time.Duration) (func(), error) {
+	recorder, err := newUsageRecorder(containerName, cgroupPath, interval)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		recorder.run(done)
+		close(finished)
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}, nil
+}
+
+func readUsageFile(containerName string) (*usageHeader, []usageRecord, error) {
+	filePath := usageFilePath(containerName)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open usage file %s error %v", filePath, err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var header usageHeader
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, nil, fmt.Errorf("read usage header error %v", err)
+	}
+	if header.Magic != usageMagic || header.Version != usageVersion {
+		return nil, nil, fmt.Errorf("unknown usage file format of %s", filePath)
+	}
+	var records []usageRecord
+	for {
+		var record usageRecord
+		if err := binary.Read(reader, binary.LittleEndian, &record); err != nil {
+			//a record cut by a crash is dropped
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, nil, fmt.Errorf("read usage record error %v", err)
+		}
+		records = append(records, record)
+	}
+	return &header, records, nil
+}
+
+// printUsage print the summary and the time series of the recorded usage of a container
+func printUsage(containerName string) error {
+	header, records, err := readUsageFile(containerName)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no usage recorded for container %s", containerName)
+	}
+	return writeUsage(os.Stdout, header, records)
+}
+
+// writeUsage write the summary and one line every sample,the cpu % of a sample is
+// the cpu time used since the previous sample over the time passed
+func writeUsage(out io.Writer, header *usageHeader, records []usageRecord) error {
+	first, last := records[0], records[len(records)-1]
+	var peakMemory uint64
+	for _, record := range records {
+		if record.Memory > peakMemory {
+			peakMemory = record.Memory
+		}
+		if record.MemoryPeak > peakMemory {
+			peakMemory = record.MemoryPeak
+		}
+	}
+	fmt.Fprintf(out, "Samples:      %d every %s\n", len(records), time.Duration(header.Interval))
+	fmt.Fprintf(out, "Recorded:     %s - %s\n",
+		time.Unix(0, first.Time).Format("2006-01-02 15:04:05"),
+		time.Unix(0, last.Time).Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(out, "Peak memory:  %s\n", formatBytes(peakMemory))
+	fmt.Fprintf(out, "CPU time:     %s\n", time.Duration(last.CpuNanos).Round(time.Millisecond))
+	fmt.Fprintf(out, "Block I/O:    %s / %s\n\n", formatBytes(last.ReadBytes), formatBytes(last.WriteBytes))
+
+	w := tabwriter.NewWriter(out, 12, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "TIME\tCPU %%\tMEM USAGE\tPIDS\tBLOCK I/O\n")
+	for i, record := range records {
+		cpuPercent := 0.0
+		if i > 0 && record.Time > records[i-1].Time && record.CpuNanos >= records[i-1].CpuNanos {
+			cpuPercent = float64(record.CpuNanos-records[i-1].CpuNanos) / float64(record.Time-records[i-1].Time) * 100
+		}
+		fmt.Fprintf(w, "%s\t%.2f%%\t%s\t%d\t%s / %s\n",
+			time.Unix(0, record.Time).Format("15:04:05"),
+			cpuPercent,
+			formatBytes(record.Memory),
+			record.Pids,
+			formatBytes(record.ReadBytes),
+			formatBytes(record.WriteBytes))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"docker-my/state"
+	"docker-my/state/statetest"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the header and every record have a fixed size in the usage file
+const (
+	testUsageHeaderSize = 16
+	testUsageRecordSize = 56
+)
+
+func TestUsageFileFormat(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	useFakeCgroupV2(t, "mydocker/abc")
+	if err := os.MkdirAll(state.Dir("abc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := newUsageRecorder("abc", "mydocker/abc", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := recorder.sample(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(usageFilePath("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != testUsageHeaderSize+2*testUsageRecordSize {
+		t.Fatalf("usage file of %d bytes,want %d", len(content), testUsageHeaderSize+2*testUsageRecordSize)
+	}
+	if string(content[:4]) != "MDUS" || binary.LittleEndian.Uint32(content[4:8]) != 1 ||
+		binary.LittleEndian.Uint64(content[8:16]) != uint64(5*time.Second) {
+		t.Errorf("usage header %x", content[:testUsageHeaderSize])
+	}
+	//the record is the time,cpu,memory,peak,pids,read and write in little endian
+	record := content[testUsageHeaderSize:]
+	if cpu, memory, pids := binary.LittleEndian.Uint64(record[8:16]), binary.LittleEndian.Uint64(record[16:24]),
+		binary.LittleEndian.Uint64(record[32:40]); cpu != 5000 || memory != 4096 || pids != 1 {
+		t.Errorf("usage record cpu %d,memory %d,pids %d", cpu, memory, pids)
+	}
+
+	//a restarted recorder append without another header
+	recorder, err = newUsageRecorder("abc", "mydocker/abc", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.sample(); err != nil {
+		t.Fatal(err)
+	}
+	//a record cut by a crash
+	recorder.file.Write(make([]byte, 10))
+	recorder.file.Close()
+	header, records, err := readUsageFile("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Interval != int64(5*time.Second) || len(records) != 3 {
+		t.Errorf("header %+v,%d records,want 3", header, len(records))
+	}
+}
+
+func TestReadUsageFileUnknownFormat(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	if err := os.MkdirAll(state.Dir("abc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(usageFilePath("abc"), []byte("JUNKJUNKJUNKJUNK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readUsageFile("abc"); err == nil || !strings.Contains(err.Error(), "unknown usage file format") {
+		t.Errorf("read junk usage file error %v", err)
+	}
+}
+
+func TestRecordUsageFinalSample(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	useFakeCgroupV2(t, "mydocker/abc")
+	if err := os.MkdirAll(state.Dir("abc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	stop, err := recordUsage("abc", "mydocker/abc", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	//the first sample and the last one taken by stop
+	if _, records, err := readUsageFile("abc"); err != nil || len(records) != 2 {
+		t.Errorf("%d records,%v,want 2", len(records), err)
+	}
+	if err := printUsage("missing"); err == nil {
+		t.Error("print the usage of a container without usage file succeeded")
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local).UnixNano()
+	header := &usageHeader{Magic: usageMagic, Version: usageVersion, Interval: int64(time.Second)}
+	records := []usageRecord{
+		{Time: start, CpuNanos: 0, Memory: 1024, MemoryPeak: 2048, Pids: 1},
+		{Time: start + int64(time.Second), CpuNanos: uint64(500 * time.Millisecond), Memory: 4096, Pids: 2, ReadBytes: 1536, WriteBytes: 512},
+	}
+	var out bytes.Buffer
+	if err := writeUsage(&out, header, records); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Samples:      2 every 1s\n",
+		"Recorded:     2024-01-02 03:04:05 - 2024-01-02 03:04:06\n",
+		"Peak memory:  4.00KiB\n",
+		"CPU time:     500ms\n",
+		"Block I/O:    1.50KiB / 512B\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output miss %q:\n%s", want, out.String())
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	//the cpu % of the first sample is 0,the second used half a cpu
+	if first, second := lines[len(lines)-2], lines[len(lines)-1]; !strings.Contains(first, "0.00%") || !strings.Contains(second, "50.00%") {
+		t.Errorf("samples:\n%s\n%s", first, second)
+	}
+}
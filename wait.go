@@ -0,0 +1,108 @@
+package main
+
+import (
+	"docker-my/container"
+	"docker-my/state"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"os"
+	"time"
+)
+
+const (
+	// the container is exited or stopped,it return at once for a container already not running
+	WaitConditionNotRunning = "not-running"
+	// the container exit after the wait begin,also when it is going to be restarted
+	WaitConditionNextExit = "next-exit"
+	// the container is removed by rm
+	WaitConditionRemoved = "removed"
+)
+
+func isRunning(containerInfo *container.ContainerInfo) bool {
+	return containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED ||
+		containerInfo.Status == container.RESTARTING
+}
+
+// exitCount is how many times the container process exited,it only grow
+// since every restart follow an exit
+func exitCount(containerInfo *container.ContainerInfo) int {
+	count := containerInfo.RestartCount
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		count++
+	}
+	return count
+}
+
+// waitContainer block until the container meet the condition and return its last exit code,
+// a zero deadline wait forever
+func waitContainer(containerName, condition string, deadline time.Time) (int, error) {
+	var first, last *container.ContainerInfo
+	for {
+		containerInfo, err := state.Get(containerName)
+		if err != nil {
+			if condition == WaitConditionRemoved && last != nil && errors.Is(err, os.ErrNotExist) {
+				return last.ExitCode, nil
+			}
+			return -1, err
+		}
+		if first == nil {
+			first = containerInfo
+		}
+		last = containerInfo
+		switch condition {
+		case WaitConditionNotRunning:
+			if !isRunning(containerInfo) {
+				return containerInfo.ExitCode, nil
+			}
+		case WaitConditionNextExit:
+			if exitCount(containerInfo) > exitCount(first) {
+				return containerInfo.ExitCode, nil
+			}
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return -1, fmt.Errorf("timeout waiting for container %s to be %s", containerName, condition)
+		}
+		time.Sleep(statePollInterval)
+	}
+}
+
+// waitContainers wait for every container in turn and print their exit codes,
+// the exit code of a single container become the exit status of the command.
+// the timeout is the total of the whole command,the containers waited later get what is left
+func waitContainers(refs []string, condition string, timeout time.Duration) error {
+	switch condition {
+	case WaitConditionNotRunning, WaitConditionNextExit, WaitConditionRemoved:
+	default:
+		return fmt.Errorf("invalid condition %s,it should be %s,%s or %s",
+			condition, WaitConditionNotRunning, WaitConditionNextExit, WaitConditionRemoved)
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	var failed []string
+	exitCode := 0
+	for _, ref := range refs {
+		containerName, err := state.Resolve(ref)
+		if err != nil {
+			log.Errorf("%v", err)
+			failed = append(failed, ref)
+			continue
+		}
+		if exitCode, err = waitContainer(containerName, condition, deadline); err != nil {
+			log.Errorf("Wait container %s error %v", containerName, err)
+			failed = append(failed, ref)
+			continue
+		}
+		fmt.Fprintln(os.Stdout, exitCode)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to wait for containers %v", failed)
+	}
+	if len(refs) == 1 && exitCode != 0 {
+		return cli.NewExitError("", exitCode)
+	}
+	return nil
+}
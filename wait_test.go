@@ -0,0 +1,123 @@
+package main
+
+import (
+	"docker-my/container"
+	"docker-my/state"
+	"docker-my/state/statetest"
+	"testing"
+	"time"
+)
+
+func TestExitCount(t *testing.T) {
+	tests := []struct {
+		status       string
+		restartCount int
+		want         int
+	}{
+		{container.RUNNING, 0, 0},
+		{container.PAUSED, 0, 0},
+		{container.EXIT, 0, 1},
+		{container.STOP, 0, 1},
+		{container.RESTARTING, 2, 3},
+		{container.RUNNING, 3, 3},
+		{container.EXIT, 3, 4},
+	}
+	for _, test := range tests {
+		info := &container.ContainerInfo{Status: test.status, RestartCount: test.restartCount}
+		if got := exitCount(info); got != test.want {
+			t.Errorf("%s restarted %d = %d,want %d", test.status, test.restartCount, got, test.want)
+		}
+	}
+}
+
+func createTestContainer(t *testing.T, info *container.ContainerInfo) {
+	t.Helper()
+	if err := state.Create(info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// changeLater update the container after the waiter has seen it at least once
+func changeLater(t *testing.T, name string, fn func(info *container.ContainerInfo) error) {
+	go func() {
+		time.Sleep(2 * statePollInterval)
+		if _, err := state.Update(name, fn); err != nil {
+			t.Errorf("update container %s error %v", name, err)
+		}
+	}()
+}
+
+func TestWaitNotRunning(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Name: "done", Id: "1", Status: container.EXIT, ExitCode: 3})
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "2", Status: container.RUNNING})
+
+	//a container already not running return at once
+	if code, err := waitContainer("done", WaitConditionNotRunning, time.Time{}); err != nil || code != 3 {
+		t.Fatalf("wait exited container = %d,%v,want 3", code, err)
+	}
+
+	changeLater(t, "web", func(info *container.ContainerInfo) error {
+		info.Status = container.EXIT
+		info.ExitCode = 137
+		return nil
+	})
+	if code, err := waitContainer("web", WaitConditionNotRunning, time.Now().Add(5*time.Second)); err != nil || code != 137 {
+		t.Fatalf("wait running container = %d,%v,want 137", code, err)
+	}
+}
+
+func TestWaitNextExit(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Name: "done", Id: "1", Status: container.EXIT, ExitCode: 3})
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "2", Status: container.RUNNING})
+
+	//the exit before the wait is not the next one
+	if _, err := waitContainer("done", WaitConditionNextExit, time.Now().Add(3*statePollInterval)); err == nil {
+		t.Fatal("wait next exit of an exited container should time out")
+	}
+
+	//the restart is also an exit,even the container is running again when it is seen
+	changeLater(t, "web", func(info *container.ContainerInfo) error {
+		info.RestartCount++
+		info.ExitCode = 1
+		return nil
+	})
+	if code, err := waitContainer("web", WaitConditionNextExit, time.Now().Add(5*time.Second)); err != nil || code != 1 {
+		t.Fatalf("wait restarted container = %d,%v,want 1", code, err)
+	}
+}
+
+func TestWaitRemoved(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Name: "done", Id: "1", Status: container.EXIT, ExitCode: 2})
+
+	go func() {
+		time.Sleep(2 * statePollInterval)
+		if err := state.Remove("done", nil); err != nil {
+			t.Errorf("remove container error %v", err)
+		}
+	}()
+	if code, err := waitContainer("done", WaitConditionRemoved, time.Now().Add(5*time.Second)); err != nil || code != 2 {
+		t.Fatalf("wait removed container = %d,%v,want 2", code, err)
+	}
+	//the container never seen is an error,not removed
+	if _, err := waitContainer("done", WaitConditionRemoved, time.Time{}); err == nil {
+		t.Fatal("wait a missing container should fail")
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	statetest.UseTempInfoLocation(t)
+	createTestContainer(t, &container.ContainerInfo{Name: "web", Id: "2", Status: container.RUNNING})
+	start := time.Now()
+	if _, err := waitContainer("web", WaitConditionNotRunning, start.Add(2*statePollInterval)); err == nil {
+		t.Fatal("want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 10*statePollInterval {
+		t.Fatalf("timeout after %v", elapsed)
+	}
+	if err := waitContainers([]string{"web"}, "forever", 0); err == nil {
+		t.Fatal("want invalid condition error")
+	}
+}